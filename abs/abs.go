@@ -0,0 +1,194 @@
+package abs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/statsutil"
+)
+
+const (
+	defaultAlpha     = 0.05
+	defaultMetric    = "ns/op"
+	defaultRunsRatio = 0.5
+)
+
+// Report is the API Benchmarking Score of an experiment.
+// A function counts as detected if at least one benchmark detects the regression introduced into it.
+type Report struct {
+	Test      data.StatTest    `json:"test"`
+	Alpha     float64          `json:"alpha"`
+	MinChange float64          `json:"min_change"`
+	Metric    string           `json:"metric"`
+	Functions []FunctionResult `json:"functions"`
+	Detected  int              `json:"detected"`
+	Total     int              `json:"total"`
+	Score     float64          `json:"score"`
+}
+
+type FunctionResult struct {
+	Function   string            `json:"function"`
+	Detected   bool              `json:"detected"`
+	Benchmarks []BenchmarkResult `json:"benchmarks"`
+}
+
+type BenchmarkResult struct {
+	Benchmark    string  `json:"benchmark"`
+	Detected     bool    `json:"detected"`
+	Runs         int     `json:"runs"`
+	DetectedRuns int     `json:"detected_runs"`
+	Change       float64 `json:"change"` // mean relative change of the medians over all runs
+}
+
+type seriesKey struct {
+	test      string
+	benchmark string
+}
+
+// runSeries holds the measurements of each run
+type runSeries map[int][]float64
+
+// Score computes the API Benchmarking Score of the results.
+// Every run of an altered function is compared to the baseline of the same run, benchmark by benchmark.
+// If functions is empty, all altered functions present in the results are considered.
+func Score(records []bench.Record, functions []string, c data.ABSConfig) (Report, error) {
+	c = withDefaults(c)
+
+	series := map[seriesKey]runSeries{}
+	benchs := map[string]struct{}{}
+	tests := map[string]struct{}{}
+	for _, r := range records {
+		v, ok := r.Metrics[c.Metric]
+		if !ok || r.Warmup {
+			continue
+		}
+		k := seriesKey{test: r.Test, benchmark: r.Benchmark}
+		rs, ok := series[k]
+		if !ok {
+			rs = runSeries{}
+			series[k] = rs
+		}
+		rs[r.Run] = append(rs[r.Run], v)
+		benchs[r.Benchmark] = struct{}{}
+		if r.Test != bench.Baseline {
+			tests[r.Test] = struct{}{}
+		}
+	}
+
+	if len(series) == 0 {
+		return Report{}, fmt.Errorf("No results for metric '%s'", c.Metric)
+	}
+
+	if len(functions) == 0 {
+		functions = sortedKeys(tests)
+	}
+	benchNames := sortedKeys(benchs)
+
+	report := Report{
+		Test:      c.Test,
+		Alpha:     c.Alpha,
+		MinChange: c.MinChange,
+		Metric:    c.Metric,
+		Functions: make([]FunctionResult, 0, len(functions)),
+		Total:     len(functions),
+	}
+
+	for _, f := range functions {
+		fr := FunctionResult{
+			Function:   f,
+			Benchmarks: []BenchmarkResult{},
+		}
+		for _, b := range benchNames {
+			br, compared := compare(series, f, b, c)
+			if !compared {
+				continue
+			}
+			fr.Benchmarks = append(fr.Benchmarks, br)
+			fr.Detected = fr.Detected || br.Detected
+		}
+		if fr.Detected {
+			report.Detected++
+		}
+		report.Functions = append(report.Functions, fr)
+	}
+
+	if report.Total > 0 {
+		report.Score = float64(report.Detected) / float64(report.Total)
+	}
+	return report, nil
+}
+
+func compare(series map[seriesKey]runSeries, test, benchmark string, c data.ABSConfig) (BenchmarkResult, bool) {
+	br := BenchmarkResult{
+		Benchmark: benchmark,
+	}
+	variants := series[seriesKey{test: test, benchmark: benchmark}]
+	baselines := series[seriesKey{test: bench.Baseline, benchmark: benchmark}]
+	var changes float64
+	for run, variant := range variants {
+		baseline, ok := baselines[run]
+		if !ok {
+			continue
+		}
+		detected, change := Detect(baseline, variant, c)
+		br.Runs++
+		changes += change
+		if detected {
+			br.DetectedRuns++
+		}
+	}
+	if br.Runs == 0 {
+		return br, false
+	}
+	br.Change = changes / float64(br.Runs)
+	br.Detected = float64(br.DetectedRuns) >= math.Ceil(c.RunsRatio*float64(br.Runs))
+	return br, true
+}
+
+// Detect decides whether the variant is slower than the baseline.
+// It returns the decision and the relative change of the medians, which has to be positive and at least the minimum change.
+func Detect(baseline, variant []float64, c data.ABSConfig) (bool, float64) {
+	c = withDefaults(c)
+	mb := statsutil.Median(baseline)
+	change := (statsutil.Median(variant) - mb) / mb
+	if math.IsNaN(change) || math.IsInf(change, 0) || change <= 0 || change < c.MinChange {
+		return false, change
+	}
+
+	switch c.Test {
+	case data.WelchTTest:
+		return statsutil.WelchTTest(variant, baseline) < c.Alpha, change
+	case data.RelativeDelta:
+		return true, change
+	default:
+		return statsutil.MannWhitneyU(variant, baseline) < c.Alpha, change
+	}
+}
+
+func withDefaults(c data.ABSConfig) data.ABSConfig {
+	if c.Test == "" {
+		c.Test = data.MannWhitneyU
+	}
+	if c.Alpha == 0 {
+		c.Alpha = defaultAlpha
+	}
+	if c.Metric == "" {
+		c.Metric = defaultMetric
+	}
+	if c.RunsRatio == 0 {
+		c.RunsRatio = defaultRunsRatio
+	}
+	return c
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
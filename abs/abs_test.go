@@ -0,0 +1,118 @@
+package abs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/data"
+)
+
+const results = `0-0-0;Baseline;pkg/a_test.go/BenchmarkA;100;10
+0-0-0;Baseline;pkg/a_test.go/BenchmarkA;100;11
+0-0-0;Baseline;pkg/a_test.go/BenchmarkA;100;10.5
+0-0-0;Baseline;pkg/a_test.go/BenchmarkA;100;9.5
+0-0-0;Baseline;pkg/a_test.go/BenchmarkA;100;10
+0-0-0;Baseline;pkg/a_test.go/BenchmarkB;100;20
+0-0-0;Baseline;pkg/a_test.go/BenchmarkB;100;21
+0-0-0;Baseline;pkg/a_test.go/BenchmarkB;100;19
+0-0-0;Baseline;pkg/a_test.go/BenchmarkB;100;20.5
+0-0-0;Baseline;pkg/a_test.go/BenchmarkB;100;19.5
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkA;100;20
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkA;100;21
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkA;100;20.5
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkA;100;19.5
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkA;100;20
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkB;100;20
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkB;100;21
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkB;100;19
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkB;100;20.5
+0-0-0;pkg.{a.go}.Slow;pkg/a_test.go/BenchmarkB;100;19.5
+0-0-0;pkg.{a.go}.Same;pkg/a_test.go/BenchmarkA;100;10
+0-0-0;pkg.{a.go}.Same;pkg/a_test.go/BenchmarkA;100;11
+0-0-0;pkg.{a.go}.Same;pkg/a_test.go/BenchmarkA;100;10.5
+0-0-0;pkg.{a.go}.Same;pkg/a_test.go/BenchmarkA;100;9.5
+0-0-0;pkg.{a.go}.Same;pkg/a_test.go/BenchmarkA;100;10
+`
+
+func records(t *testing.T) []bench.Record {
+	rs, err := bench.ReadCSV(strings.NewReader(results))
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	return rs
+}
+
+func testScore(test data.StatTest, t *testing.T) {
+	c := data.ABSConfig{
+		Test:      test,
+		MinChange: 0.05,
+	}
+	report, err := Score(records(t), nil, c)
+	if err != nil {
+		t.Fatalf("Could not compute score: %v", err)
+	}
+
+	if report.Total != 2 {
+		t.Errorf("Unexpected total: expected 2, was %d", report.Total)
+	}
+	if report.Detected != 1 {
+		t.Errorf("Unexpected detected functions: expected 1, was %d", report.Detected)
+	}
+	if report.Score != 0.5 {
+		t.Errorf("Unexpected score: expected 0.5, was %f", report.Score)
+	}
+
+	for _, f := range report.Functions {
+		switch f.Function {
+		case "pkg.{a.go}.Slow":
+			if !f.Detected || len(f.Benchmarks) != 2 {
+				t.Errorf("Unexpected result for %s: %+v", f.Function, f)
+			}
+			for _, b := range f.Benchmarks {
+				expected := strings.HasSuffix(b.Benchmark, "BenchmarkA")
+				if b.Detected != expected {
+					t.Errorf("Unexpected detection of %s by %s: expected %t", f.Function, b.Benchmark, expected)
+				}
+			}
+		case "pkg.{a.go}.Same":
+			if f.Detected || len(f.Benchmarks) != 1 {
+				t.Errorf("Unexpected result for %s: %+v", f.Function, f)
+			}
+		default:
+			t.Errorf("Unexpected function %s", f.Function)
+		}
+	}
+}
+
+func TestScoreMannWhitneyU(t *testing.T) {
+	testScore(data.MannWhitneyU, t)
+}
+
+func TestScoreWelchTTest(t *testing.T) {
+	testScore(data.WelchTTest, t)
+}
+
+func TestScoreRelativeDelta(t *testing.T) {
+	testScore(data.RelativeDelta, t)
+}
+
+func TestScoreConfiguredFunctions(t *testing.T) {
+	funs := []string{"pkg.{a.go}.Slow", "pkg.{b.go}.NotExecuted"}
+	report, err := Score(records(t), funs, data.ABSConfig{})
+	if err != nil {
+		t.Fatalf("Could not compute score: %v", err)
+	}
+	if report.Total != 2 || report.Detected != 1 {
+		t.Errorf("Unexpected score: expected 1 of 2, was %d of %d", report.Detected, report.Total)
+	}
+}
+
+func TestDetectUnchanged(t *testing.T) {
+	samples := []float64{10, 11, 10.5, 9.5, 10}
+	for _, test := range []data.StatTest{data.MannWhitneyU, data.WelchTTest, data.RelativeDelta} {
+		if detected, _ := Detect(samples, samples, data.ABSConfig{Test: test}); detected {
+			t.Errorf("Unexpected detection of unchanged variant with %s", test)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/statsutil"
+)
+
+const (
+	defaultAdaptiveMetric          = "ns/op"
+	defaultAdaptiveConfidence      = 0.95
+	defaultAdaptiveMaxMeasurements = 100
+	adaptiveResamples              = 1000
+	minAdaptiveMeasurements        = 2
+)
+
+func withAdaptiveDefaults(a data.Adaptive, mi int) data.Adaptive {
+	if a.Statistic == "" {
+		a.Statistic = data.MeanStatistic
+	}
+	if a.Metric == "" {
+		a.Metric = defaultAdaptiveMetric
+	}
+	if a.Confidence == 0 {
+		a.Confidence = defaultAdaptiveConfidence
+	}
+	if a.MinMeasurements == 0 {
+		a.MinMeasurements = mi
+	}
+	// the confidence interval of a single measurement is meaningless
+	if a.MinMeasurements < minAdaptiveMeasurements {
+		a.MinMeasurements = minAdaptiveMeasurements
+	}
+	// always stop eventually
+	if a.MaxMeasurements == 0 && a.MaxDuration == 0 {
+		a.MaxMeasurements = defaultAdaptiveMaxMeasurements
+	}
+	return a
+}
+
+// runAdaptive executes the benchmark until the confidence intervals of all its result series
+// (sub-benchmarks) are narrower than the configured width or the budget is exhausted.
+func (r *runnerWithPenalty) runAdaptive(ctx context.Context, bench data.Function, run int, suiteExec int, v Variant) (int, error) {
+	a := r.adaptive
+	relBenchName := relBenchName(bench)
+	rnd := rand.New(rand.NewSource(int64(run)))
+	series := map[string][]float64{}
+	start := time.Now()
+	benchCount := 0
+	for {
+		recs, exec, err := r.runBenchmarkOnce(ctx, bench, run, suiteExec, benchCount, v)
+		if err != nil || !exec {
+			return benchCount, err
+		}
+		benchCount++
+
+		n := addMeasurements(series, recs, a.Metric)
+		if n == 0 {
+			fmt.Printf("### No '%s' measurements of %s, stop adaptive execution\n", a.Metric, relBenchName)
+			return benchCount, nil
+		}
+
+		width := ciWidth(series, a, rnd)
+		if n >= a.MinMeasurements && width <= a.CIWidth {
+			fmt.Printf("### %s stable after %d measurements (CI width %f)\n", relBenchName, n, width)
+			return benchCount, nil
+		}
+		if a.MaxMeasurements > 0 && n >= a.MaxMeasurements {
+			fmt.Printf("### %s not stable after %d measurements (CI width %f)\n", relBenchName, n, width)
+			return benchCount, nil
+		}
+		if a.MaxDuration > 0 && time.Since(start) >= a.MaxDuration.ToStdLib() {
+			fmt.Printf("### %s not stable after %s (CI width %f)\n", relBenchName, a.MaxDuration.ToStdLib(), width)
+			return benchCount, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return benchCount, ctx.Err()
+		default:
+		}
+	}
+}
+
+// addMeasurements adds the metric values of recs (without warm-ups) to series and returns the number of measurements of the smallest series
+func addMeasurements(series map[string][]float64, recs []Record, metric string) int {
+	for _, rec := range recs {
+		if rec.Warmup {
+			continue
+		}
+		if v, ok := rec.Metrics[metric]; ok {
+			series[rec.Benchmark] = append(series[rec.Benchmark], v)
+		}
+	}
+
+	if len(series) == 0 {
+		return 0
+	}
+	n := math.MaxInt32
+	for _, ms := range series {
+		if len(ms) < n {
+			n = len(ms)
+		}
+	}
+	return n
+}
+
+// ciWidth returns the largest relative bootstrap confidence interval width of all series
+func ciWidth(series map[string][]float64, a data.Adaptive, r *rand.Rand) float64 {
+	statistic := statsutil.Mean
+	if a.Statistic == data.MedianStatistic {
+		statistic = statsutil.Median
+	}
+
+	var max float64
+	for _, ms := range series {
+		lo, hi := statsutil.BootstrapCI([][]float64{ms}, statistic, a.Confidence, adaptiveResamples, r)
+		w := (hi - lo) / statistic(ms)
+		if math.IsNaN(w) || math.IsInf(w, 0) {
+			return math.Inf(1)
+		}
+		if w > max {
+			max = w
+		}
+	}
+	return max
+}
@@ -0,0 +1,40 @@
+package bench
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func TestAdaptiveStopping(t *testing.T) {
+	a := withAdaptiveDefaults(data.Adaptive{CIWidth: 0.05}, 1)
+	if a.MinMeasurements != minAdaptiveMeasurements || a.MaxMeasurements != defaultAdaptiveMaxMeasurements {
+		t.Fatalf("Unexpected defaults: %+v", a)
+	}
+
+	series := map[string][]float64{}
+	res := []Record{
+		{Benchmark: "BenchmarkA", Metrics: map[string]float64{timeUnit: 100}},
+		{Benchmark: "BenchmarkA", Metrics: map[string]float64{timeUnit: 1000}, Warmup: true},
+		{Benchmark: "BenchmarkB/x", Metrics: map[string]float64{timeUnit: 10}},
+		{Benchmark: "BenchmarkB/x", Metrics: map[string]float64{timeUnit: 10}},
+	}
+	if n := addMeasurements(series, res, timeUnit); n != 1 {
+		t.Fatalf("Expected 1 measurement of the smallest series, got %d", n)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	if w := ciWidth(series, a, r); w != 0 {
+		t.Fatalf("Expected CI width 0 of constant measurements, got %f", w)
+	}
+
+	series["BenchmarkA"] = append(series["BenchmarkA"], 150, 50, 120)
+	if w := ciWidth(series, a, r); w <= a.CIWidth {
+		t.Fatalf("Expected noisy series to exceed CI width %f, got %f", a.CIWidth, w)
+	}
+
+	if n := addMeasurements(map[string][]float64{}, res, "B/op"); n != 0 {
+		t.Fatalf("Expected no measurements of missing metric, got %d", n)
+	}
+}
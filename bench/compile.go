@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	cmdArgsCompile       = "-c"
+	cmdArgsOut           = "-o"
+	testBinarySuffix     = ".test"
+	testBinaryFlagPrefix = "-test."
+	rootBinaryName       = "root"
+)
+
+// binaries holds the test binaries of the variants currently executed; safe for concurrent use.
+// Test binaries of different packages and variants are compiled concurrently.
+type binaries struct {
+	l   sync.Mutex // guards m and n
+	dir string
+	m   map[binaryKey]*binary // binary by variant and package
+	n   int                   // number of compiled binaries
+}
+
+type binaryKey struct {
+	test string
+	pkg  string
+}
+
+// binary is a test binary that is compiled once; bin, out, and err are set when done is closed
+type binary struct {
+	done chan struct{}
+	bin  string
+	out  []byte // output of a failed compilation
+	err  error
+}
+
+func newBinaries(dir string) *binaries {
+	return &binaries{
+		dir: dir,
+		m:   make(map[binaryKey]*binary),
+	}
+}
+
+// binary returns the test binary of pkg for variant v, it is compiled with go test -c if necessary.
+// If the compilation fails, the output of the go command is returned as well.
+// Concurrent calls for the same package and variant wait for a single compilation.
+func (r *runnerWithPenalty) binary(ctx context.Context, pkg string, v Variant) (string, []byte, error) {
+	b := r.binaries
+	k := binaryKey{test: v.Test, pkg: pkg}
+
+	b.l.Lock()
+	if e, ok := b.m[k]; ok {
+		b.l.Unlock()
+		select {
+		case <-e.done:
+			// do not compile a broken package for every benchmark
+			return e.bin, e.out, e.err
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+	e := &binary{done: make(chan struct{})}
+	b.m[k] = e
+	// variant names are not valid file names
+	b.n++
+	n := b.n
+	b.l.Unlock()
+
+	e.bin, e.out, e.err = r.compile(ctx, pkg, v, n)
+	if e.err != nil && ctx.Err() != nil {
+		// canceled compilations are repeated
+		b.l.Lock()
+		delete(b.m, k)
+		b.l.Unlock()
+	}
+	close(e.done)
+	return e.bin, e.out, e.err
+}
+
+// compile compiles the test binary of pkg for variant v into the n-th binary file
+func (r *runnerWithPenalty) compile(ctx context.Context, pkg string, v Variant, n int) (string, []byte, error) {
+	name := replaceSlashes(pkg)
+	if name == "" {
+		name = rootBinaryName
+	}
+	bin, err := filepath.Abs(filepath.Join(r.binaries.dir, fmt.Sprintf("%d_%s%s", n, name, testBinarySuffix)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make([]string, 0, len(v.BuildArgs)+4)
+	args = append(args, cmdArgsTest, cmdArgsCompile, cmdArgsOut, bin)
+	args = append(args, v.BuildArgs...)
+	fmt.Printf("### Compile Benchmarks of Package: %s\n", pkg)
+	c := r.goCommand(args)
+	c.Dir = filepath.Join(r.projectRoot, pkg)
+	c.Env = r.env
+	out, err := Supervise(ctx, c, r.deadline, r.killGrace)
+	if err != nil {
+		return "", out, err
+	}
+	return bin, nil, nil
+}
+
+// retainBinaries removes the test binaries of all variants but vs
+func (r *runnerWithPenalty) retainBinaries(vs ...Variant) {
+	b := r.binaries
+	if b == nil {
+		return
+	}
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	retain := make(map[string]struct{}, len(vs))
+	for _, v := range vs {
+		retain[v.Test] = struct{}{}
+	}
+	for k, e := range b.m {
+		if _, ok := retain[k.test]; ok {
+			continue
+		}
+		delete(b.m, k)
+		select {
+		case <-e.done:
+			if e.bin != "" {
+				os.Remove(e.bin)
+			}
+		default:
+			// still compiling, the binary is removed with the binary folder
+		}
+	}
+}
+
+// testBinaryArgs converts go test flags into the flags of a test binary (e.g., -benchtime=1s to -test.benchtime=1s)
+func testBinaryArgs(args []string) []string {
+	ret := make([]string, 0, len(args))
+	for _, a := range args {
+		ret = append(ret, testBinaryFlagPrefix+strings.TrimPrefix(a, "-"))
+	}
+	return ret
+}
@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func TestTestBinaryArgs(t *testing.T) {
+	args := []string{"-benchtime=1s", "-timeout=10m0s", "-count=5", "-run=^$", "-benchmem", "-bench=^BenchmarkA$/^x$"}
+	exp := []string{"-test.benchtime=1s", "-test.timeout=10m0s", "-test.count=5", "-test.run=^$", "-test.benchmem", "-test.bench=^BenchmarkA$/^x$"}
+	if got := testBinaryArgs(args); !reflect.DeepEqual(got, exp) {
+		t.Errorf("Unexpected test binary args\nexpected: %v\nwas:      %v", exp, got)
+	}
+}
+
+func TestBinaryConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-compile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "project")
+	files := map[string]string{
+		"go.mod":      "module example.com/compile\n",
+		"a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc BenchmarkA(b *testing.B) {}\n",
+		"b/b_test.go": "package b\n\nimport \"testing\"\n\nfunc BenchmarkB(b *testing.B) {}\n",
+	}
+	for p, src := range files {
+		p = filepath.Join(project, p)
+		err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(p, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base, err := NewRunner(RunnerConfig{
+		GoRoot:                runtime.GOROOT(),
+		ProjectRoot:           project,
+		Benchs:                data.PackageMap{},
+		MeasurementIterations: 1,
+		Timeout:               time.Minute,
+		BenchTime:             time.Millisecond,
+		Penalty:               data.PermanentPenalty,
+		BinDir:                filepath.Join(dir, "bin"),
+	})
+	if err != nil {
+		t.Fatalf("Could not create runner: %v", err)
+	}
+	r := base.(*runnerWithPenalty)
+	v := Variant{Test: Baseline}
+
+	// package a is compiled forever
+	r.binaries.m[binaryKey{test: v.Test, pkg: "a"}] = &binary{done: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	bins := make([]string, 3)
+	errs := make([]error, 3)
+	for i := range bins {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bins[i], _, errs[i] = r.binary(context.Background(), "b", v)
+		}(i)
+	}
+	wg.Wait()
+	for i := range bins {
+		if errs[i] != nil || bins[i] != bins[0] {
+			t.Errorf("Expected single binary of package b, was %v (%v)", bins, errs)
+			break
+		}
+	}
+	if r.binaries.n != 1 {
+		t.Errorf("Expected 1 compilation, was %d", r.binaries.n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := r.binary(ctx, "a", v); err != context.DeadlineExceeded {
+		t.Errorf("Expected to wait for the compilation of package a, was %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package bench
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Failure is a benchmark execution that did not produce results
+type Failure struct {
+	Run       int
+	SuiteExec int
+	BenchExec int
+	Test      string
+	Benchmark string
+	Reason    FailureReason
+	Message   string // tail of the output
+	Penalised bool   // whether the benchmark is not executed anymore because of the failure
+}
+
+// FailureSink is implemented by sinks that record failed benchmark executions
+type FailureSink interface {
+	WriteFailure(f Failure) error
+}
+
+// FailureLog is the failure report of an experiment, it writes one JSON object per failed benchmark execution and line.
+// Safe for concurrent use.
+type FailureLog struct {
+	l      sync.Mutex
+	f      *os.File
+	e      *json.Encoder
+	counts map[FailureReason]int
+}
+
+// OpenFailureLog opens the failure log at path.
+// If resume is false, an existing log is truncated, otherwise failures are appended.
+func OpenFailureLog(path string, resume bool) (*FailureLog, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &FailureLog{
+		f:      f,
+		e:      json.NewEncoder(f),
+		counts: map[FailureReason]int{},
+	}, nil
+}
+
+func (l *FailureLog) WriteFailure(f Failure) error {
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.counts[f.Reason]++
+	return l.e.Encode(newJSONFailure(f))
+}
+
+// Counts returns the number of failures written by reason.
+func (l *FailureLog) Counts() map[FailureReason]int {
+	l.l.Lock()
+	defer l.l.Unlock()
+	ret := make(map[FailureReason]int, len(l.counts))
+	for r, n := range l.counts {
+		ret[r] = n
+	}
+	return ret
+}
+
+func (l *FailureLog) Close() error {
+	return l.f.Close()
+}
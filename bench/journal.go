@@ -0,0 +1,288 @@
+package bench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	journalDone      = "done"
+	journalPenalised = "penalised"
+	journalFailed    = "failed"
+	// the benchmark field of experiment entries is the experiment of the results
+	journalExperiment = "experiment"
+	// a regression was introduced into (altered) or removed from (restored) the project for the test of the entry
+	journalAltered  = "altered"
+	journalRestored = "restored"
+	journalCols     = 5
+)
+
+type journalKey struct {
+	run       int
+	suiteExec int
+	test      string
+	bench     string
+}
+
+// Journal records which benchmark executions of an experiment completed,
+// which allows for resuming an interrupted experiment.
+// Every line is of the form status;run;suiteExec;test;benchmark. The experiment of SQLite results is recorded in an experiment entry.
+// Safe for concurrent use.
+type Journal struct {
+	l          sync.Mutex
+	f          *os.File
+	w          *csv.Writer
+	done       map[journalKey]struct{}
+	completed  map[journalKey]struct{} // done with the benchmark name of the results (e.g., pkg/a_test.go/BenchmarkA)
+	penalised  map[string]int          // run of the penalty by benchmark
+	failures   map[string]int          // failures by benchmark
+	experiment string
+	altered    string // test whose regression was not restored
+}
+
+// OpenJournal opens the journal at path.
+// If resume is false, an existing journal is truncated, otherwise its entries are loaded.
+func OpenJournal(path string, resume bool) (*Journal, error) {
+	j := &Journal{
+		done:      map[journalKey]struct{}{},
+		completed: map[journalKey]struct{}{},
+		penalised: map[string]int{},
+		failures:  map[string]int{},
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		err := j.load(path)
+		if err != nil {
+			return nil, err
+		}
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	j.f = f
+	j.w = csv.NewWriter(f)
+	j.w.Comma = ';'
+	return j, nil
+}
+
+func (j *Journal) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ';'
+	// journals of earlier versions contain the size of the results as last field
+	r.FieldsPerRecord = -1
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			// the last line is incomplete if goabs was killed while writing it
+			if _, ok := err.(*csv.ParseError); ok {
+				fmt.Printf("Ignoring invalid journal entry: %v\n", err)
+				return nil
+			}
+			return err
+		}
+		if len(rec) < journalCols {
+			fmt.Printf("Ignoring invalid journal entry: %s\n", strings.Join(rec, ";"))
+			return nil
+		}
+
+		if rec[0] == journalExperiment {
+			j.experiment = rec[4]
+			continue
+		}
+
+		run, err := strconv.Atoi(rec[1])
+		if err != nil {
+			return fmt.Errorf("Invalid run in journal: %s", rec[1])
+		}
+		suiteExec, err := strconv.Atoi(rec[2])
+		if err != nil {
+			return fmt.Errorf("Invalid suite execution in journal: %s", rec[2])
+		}
+		k := journalKey{run: run, suiteExec: suiteExec, test: rec[3], bench: rec[4]}
+
+		switch rec[0] {
+		case journalDone:
+			j.complete(k)
+		case journalAltered:
+			j.altered = k.test
+		case journalRestored:
+			j.altered = ""
+		case journalPenalised:
+			j.penalised[k.bench] = run
+		case journalFailed:
+			j.failures[k.bench]++
+		default:
+			return fmt.Errorf("Invalid journal status: %s", rec[0])
+		}
+	}
+}
+
+// Done reports whether the benchmark execution completed before.
+func (j *Journal) Done(run, suiteExec int, test, bench string) bool {
+	j.l.Lock()
+	defer j.l.Unlock()
+	_, ok := j.done[journalKey{run: run, suiteExec: suiteExec, test: test, bench: bench}]
+	return ok
+}
+
+// Completed reports whether the result of benchmark (including sub-benchmarks, e.g., pkg/a_test.go/BenchmarkA/sub)
+// belongs to a completed benchmark execution.
+func (j *Journal) Completed(run, suiteExec int, test, benchmark string) bool {
+	j.l.Lock()
+	defer j.l.Unlock()
+	name := strings.TrimPrefix(benchmark, "/")
+	for {
+		if _, ok := j.completed[journalKey{run: run, suiteExec: suiteExec, test: test, bench: name}]; ok {
+			return true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// Complete records a completed benchmark execution.
+func (j *Journal) Complete(run, suiteExec int, test, bench string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.complete(journalKey{run: run, suiteExec: suiteExec, test: test, bench: bench})
+	return j.write(journalDone, run, suiteExec, test, bench)
+}
+
+func (j *Journal) complete(k journalKey) {
+	j.done[k] = struct{}{}
+	rk := k
+	rk.bench = resultName(k.bench)
+	j.completed[rk] = struct{}{}
+}
+
+// resultName returns the benchmark name of the results (pkg/file/name) of the benchmark name of the journal (pkg/file::name)
+func resultName(bench string) string {
+	i := strings.Index(bench, "::")
+	if i < 0 {
+		return strings.TrimPrefix(bench, "/")
+	}
+	return strings.TrimPrefix(filepath.Join(bench[:i], bench[i+2:]), "/")
+}
+
+// Alter records that a regression was introduced into the project for test.
+func (j *Journal) Alter(run int, test string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.altered = test
+	return j.write(journalAltered, run, 0, test, "")
+}
+
+// Restore records that the regression of test was removed from the project.
+func (j *Journal) Restore(run int, test string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.altered = ""
+	return j.write(journalRestored, run, 0, test, "")
+}
+
+// Altered returns the test whose regression was introduced but not removed, empty if the project is unaltered.
+func (j *Journal) Altered() string {
+	j.l.Lock()
+	defer j.l.Unlock()
+	return j.altered
+}
+
+// Fail records a failed execution of the benchmark.
+func (j *Journal) Fail(run, suiteExec int, test, bench string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.failures[bench]++
+	return j.write(journalFailed, run, suiteExec, test, bench)
+}
+
+// Penalise records that the benchmark got penalised.
+func (j *Journal) Penalise(run, suiteExec int, test, bench string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.penalised[bench] = run
+	return j.write(journalPenalised, run, suiteExec, test, bench)
+}
+
+// Penalised returns the run of the (last) penalty by penalised benchmark.
+func (j *Journal) Penalised() map[string]int {
+	j.l.Lock()
+	defer j.l.Unlock()
+	ret := make(map[string]int, len(j.penalised))
+	for b, run := range j.penalised {
+		ret[b] = run
+	}
+	return ret
+}
+
+// Failures returns the number of failed executions by benchmark.
+func (j *Journal) Failures() map[string]int {
+	j.l.Lock()
+	defer j.l.Unlock()
+	ret := make(map[string]int, len(j.failures))
+	for b, n := range j.failures {
+		ret[b] = n
+	}
+	return ret
+}
+
+// Experiment returns the experiment the results are tagged with, empty if none was recorded.
+func (j *Journal) Experiment() string {
+	j.l.Lock()
+	defer j.l.Unlock()
+	return j.experiment
+}
+
+// SetExperiment records the experiment the results are tagged with.
+func (j *Journal) SetExperiment(experiment string) error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.experiment = experiment
+	return j.write(journalExperiment, 0, 0, "", experiment)
+}
+
+func (j *Journal) write(status string, run, suiteExec int, test, bench string) error {
+	err := j.w.Write([]string{
+		status,
+		strconv.Itoa(run),
+		strconv.Itoa(suiteExec),
+		test,
+		bench,
+	})
+	if err != nil {
+		return err
+	}
+	j.w.Flush()
+	err = j.w.Error()
+	if err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+func (j *Journal) Close() error {
+	j.w.Flush()
+	return j.f.Close()
+}
@@ -0,0 +1,172 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const failingBenchSrc = `package fail
+
+import "testing"
+
+func BenchmarkFail(b *testing.B) {
+	panic("fail")
+}
+`
+
+func TestStrikeNotCompleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "project")
+	err = os.MkdirAll(project, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(project, "go.mod"), []byte("module example.com/fail\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(project, "fail_test.go"), []byte(failingBenchSrc), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "out.journal")
+	j, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+
+	b := data.Function{Pkg: "", File: "fail_test.go", Name: "BenchmarkFail"}
+	benchs := data.PackageMap{"": data.FileMap{b.File: data.File{b}}}
+	out := &bytes.Buffer{}
+	r, err := NewRunner(RunnerConfig{
+		GoRoot:                runtime.GOROOT(),
+		ProjectRoot:           project,
+		Benchs:                benchs,
+		MeasurementIterations: 1,
+		Timeout:               time.Minute,
+		BenchTime:             time.Millisecond,
+		Out:                   NewCSVSink(out, false),
+		Journal:               j,
+		Penalty:               data.StrikesPenalty,
+		Strikes:               2,
+	})
+	if err != nil {
+		t.Fatalf("Could not create runner: %v", err)
+	}
+
+	executed, err := r.Run(context.Background(), 0, Variant{Test: Baseline})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if executed != 0 {
+		t.Errorf("Expected no executions, was %d", executed)
+	}
+	name := relBenchName(b)
+	if j.Done(0, 0, Baseline, name) {
+		t.Errorf("Expected failed execution not to be completed")
+	}
+	j.Close()
+
+	j, err = OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("Could not resume journal: %v", err)
+	}
+	defer j.Close()
+	if j.Done(0, 0, Baseline, name) {
+		t.Errorf("Expected failed execution to be repeated after resuming")
+	}
+	if f := j.Failures(); f[name] != 1 {
+		t.Errorf("Unexpected failures: %v", f)
+	}
+	if p := j.Penalised(); len(p) != 0 {
+		t.Errorf("Unexpected penalties: %v", p)
+	}
+}
+
+func TestJournalResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.journal")
+	j, err := OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+	if j.Experiment() != "" || j.Altered() != "" {
+		t.Errorf("Expected no experiment and regression of new journal, was '%s' and '%s'", j.Experiment(), j.Altered())
+	}
+	j.SetExperiment("e")
+	j.Complete(0, 0, Baseline, "pkg/a_test.go::BenchmarkA")
+	j.Complete(0, 0, Baseline, "/b_test.go::BenchmarkB/sub")
+	j.Fail(0, 1, Baseline, "pkg/a_test.go::BenchmarkA")
+	j.Alter(0, "f")
+	j.Restore(0, "f")
+	j.Alter(0, "g")
+	j.Close()
+	// goabs was killed while writing the last entry
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("done;0;1;\"baseline")
+	f.Close()
+
+	j, err = OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("Could not resume journal: %v", err)
+	}
+	if !j.Done(0, 0, Baseline, "pkg/a_test.go::BenchmarkA") || !j.Done(0, 0, Baseline, "/b_test.go::BenchmarkB/sub") || j.Done(0, 1, Baseline, "pkg/a_test.go::BenchmarkA") {
+		t.Errorf("Unexpected completed executions")
+	}
+	completed := []struct {
+		suiteExec int
+		benchmark string
+		exp       bool
+	}{
+		{0, "pkg/a_test.go/BenchmarkA", true},
+		{0, "pkg/a_test.go/BenchmarkA/sub", true},
+		{0, "pkg/a_test.go/BenchmarkAB", false},
+		{1, "pkg/a_test.go/BenchmarkA", false},
+		{0, "b_test.go/BenchmarkB/sub", true},
+		{0, "b_test.go/BenchmarkB/other", false},
+		{0, "b_test.go/BenchmarkB", false},
+	}
+	for _, c := range completed {
+		if j.Completed(0, c.suiteExec, Baseline, c.benchmark) != c.exp {
+			t.Errorf("Expected results of %s in suite execution %d to be completed: %t", c.benchmark, c.suiteExec, c.exp)
+		}
+	}
+	if j.Experiment() != "e" {
+		t.Errorf("Expected experiment e, was '%s'", j.Experiment())
+	}
+	if j.Altered() != "g" {
+		t.Errorf("Expected regression of g not to be reset, was '%s'", j.Altered())
+	}
+	j.Close()
+
+	j, err = OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+	defer j.Close()
+	if j.Done(0, 0, Baseline, "pkg/a_test.go::BenchmarkA") || j.Experiment() != "" || j.Altered() != "" {
+		t.Errorf("Expected journal to be truncated")
+	}
+}
@@ -0,0 +1,189 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+// Sorted returns the benchmarks sorted by package and file, benchmarks of the same file remain in declaration order
+func Sorted(benchs data.PackageMap) []data.Function {
+	pkgs := make([]string, 0, len(benchs))
+	for pkg := range benchs {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	ret := []data.Function{}
+	for _, pkg := range pkgs {
+		files := make([]string, 0, len(benchs[pkg]))
+		for f := range benchs[pkg] {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			ret = append(ret, benchs[pkg][f]...)
+		}
+	}
+	return ret
+}
+
+// OrderRand returns a random number generator derived from seed and keys.
+// The same seed and keys always result in the same order, independent of what was executed before (e.g., when resuming).
+func OrderRand(seed int64, keys ...interface{}) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprint(h, seed)
+	for _, k := range keys {
+		fmt.Fprintf(h, "-%v", k)
+	}
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// suite returns the benchmarks of a suite execution in execution order
+func (r *defaultRunner) suite(run, suiteExec int, test string) []data.Function {
+	if r.order == data.DeterministicOrder || r.order == "" {
+		return r.sorted
+	}
+
+	ret := make([]data.Function, len(r.sorted))
+	copy(ret, r.sorted)
+	rnd := OrderRand(r.seed, run, suiteExec, test)
+	rnd.Shuffle(len(ret), func(i, j int) {
+		ret[i], ret[j] = ret[j], ret[i]
+	})
+	return ret
+}
+
+// interleaved returns the variants of a benchmark of an interleaved run in execution order
+func (r *defaultRunner) interleaved(run int, bench data.Function, vs []Variant) []Variant {
+	ret := make([]Variant, len(vs))
+	copy(ret, vs)
+	rnd := OrderRand(r.seed, run, relBenchName(bench))
+	rnd.Shuffle(len(ret), func(i, j int) {
+		ret[i], ret[j] = ret[j], ret[i]
+	})
+	return ret
+}
+
+// printLocation prints the package and file of bench if they differ from the ones of prev
+func printLocation(prev *data.Function, bench data.Function) {
+	if prev == nil || prev.Pkg != bench.Pkg {
+		fmt.Printf("# Execute Benchmarks in Dir: %s\n", bench.Pkg)
+	}
+	if prev == nil || prev.Pkg != bench.Pkg || prev.File != bench.File {
+		fmt.Printf("## Execute Benchmarks of File: %s\n", bench.File)
+	}
+}
+
+// orderHeader is the first line of an order log
+type orderHeader struct {
+	Order data.Order `json:"order"`
+	Seed  int64      `json:"seed"`
+}
+
+// orderEntry is a benchmark execution of an order log
+type orderEntry struct {
+	Run       int    `json:"run"`
+	SuiteExec int    `json:"suiteExec"`
+	BenchExec int    `json:"benchExec"`
+	Test      string `json:"test"`
+	Benchmark string `json:"benchmark"`
+}
+
+// OrderLog records the seed and the realised order of benchmark executions.
+// The first line contains order and seed, every other line a benchmark execution (JSON Lines). Safe for concurrent use.
+type OrderLog struct {
+	l    sync.Mutex
+	f    *os.File
+	e    *json.Encoder
+	seed int64
+}
+
+// OpenOrderLog opens the order log at path.
+// If resume is true and the log exists, the seed of the interrupted experiment is used instead of seed.
+func OpenOrderLog(path string, resume bool, order data.Order, seed int64) (*OrderLog, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	writeHeader := true
+	if resume {
+		h, ok, err := readOrderHeader(path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if h.Order != order {
+				return nil, fmt.Errorf("Order '%s' differs from order '%s' of the interrupted experiment", order, h.Order)
+			}
+			seed = h.Seed
+			writeHeader = false
+		}
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	l := &OrderLog{
+		f:    f,
+		e:    json.NewEncoder(f),
+		seed: seed,
+	}
+	if writeHeader {
+		err = l.e.Encode(orderHeader{Order: order, Seed: seed})
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func readOrderHeader(path string) (orderHeader, bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return orderHeader{}, false, nil
+	} else if err != nil {
+		return orderHeader{}, false, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return orderHeader{}, false, s.Err()
+	}
+	var h orderHeader
+	err = json.Unmarshal(s.Bytes(), &h)
+	if err != nil {
+		return orderHeader{}, false, fmt.Errorf("Invalid order log header: %v", err)
+	}
+	return h, true, nil
+}
+
+// Seed returns the seed of the experiment.
+func (l *OrderLog) Seed() int64 {
+	return l.seed
+}
+
+func (l *OrderLog) write(run, suiteExec, benchExec int, test, bench string) error {
+	l.l.Lock()
+	defer l.l.Unlock()
+	return l.e.Encode(orderEntry{
+		Run:       run,
+		SuiteExec: suiteExec,
+		BenchExec: benchExec,
+		Test:      test,
+		Benchmark: bench,
+	})
+}
+
+func (l *OrderLog) Close() error {
+	return l.f.Close()
+}
@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func orderBenchs() data.PackageMap {
+	return data.PackageMap{
+		"/b": {"b_test.go": {{Pkg: "/b", File: "b_test.go", Name: "BenchmarkB2"}, {Pkg: "/b", File: "b_test.go", Name: "BenchmarkB1"}}},
+		"/a": {
+			"z_test.go": {{Pkg: "/a", File: "z_test.go", Name: "BenchmarkZ"}},
+			"a_test.go": {{Pkg: "/a", File: "a_test.go", Name: "BenchmarkA"}},
+		},
+	}
+}
+
+func names(fs []data.Function) []string {
+	ret := make([]string, 0, len(fs))
+	for _, f := range fs {
+		ret = append(ret, f.Name)
+	}
+	return ret
+}
+
+func TestSuiteOrder(t *testing.T) {
+	r := &defaultRunner{order: data.DeterministicOrder, sorted: Sorted(orderBenchs()), seed: 42}
+	exp := []string{"BenchmarkA", "BenchmarkZ", "BenchmarkB2", "BenchmarkB1"}
+	if got := names(r.suite(1, 2, Baseline)); !reflect.DeepEqual(got, exp) {
+		t.Errorf("Unexpected deterministic order: %v", got)
+	}
+
+	r.order = data.ShuffleOrder
+	first := names(r.suite(1, 2, Baseline))
+	if got := names(r.suite(1, 2, Baseline)); !reflect.DeepEqual(got, first) {
+		t.Errorf("Shuffle not reproducible: %v and %v", first, got)
+	}
+	if got := names(r.sorted); !reflect.DeepEqual(got, exp) {
+		t.Errorf("Shuffle altered deterministic order: %v", got)
+	}
+
+	vs := []Variant{{Test: Baseline}, {Test: "f1"}, {Test: "f2"}}
+	iv := r.interleaved(0, r.sorted[0], vs)
+	if len(iv) != len(vs) || !reflect.DeepEqual(iv, r.interleaved(0, r.sorted[0], vs)) {
+		t.Errorf("Unexpected interleaved variants: %v", iv)
+	}
+}
+
+func TestOrderLogResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.order")
+	l, err := OpenOrderLog(path, false, data.ShuffleOrder, 42)
+	if err != nil {
+		t.Fatalf("Could not open order log: %v", err)
+	}
+	l.write(0, 0, 0, Baseline, "/a/a_test.go::BenchmarkA")
+	l.Close()
+
+	l, err = OpenOrderLog(path, true, data.ShuffleOrder, 7)
+	if err != nil {
+		t.Fatalf("Could not resume order log: %v", err)
+	}
+	defer l.Close()
+	if l.Seed() != 42 {
+		t.Errorf("Expected seed of interrupted experiment 42, was %d", l.Seed())
+	}
+
+	_, err = OpenOrderLog(path, true, data.RMITOrder, 7)
+	if err == nil {
+		t.Errorf("Expected error for different order")
+	}
+}
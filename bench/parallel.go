@@ -0,0 +1,254 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+// NewParallelRunner creates a runner that executes the benchmarks of base concurrently with a pool of workers.
+// Every worker executes its benchmarks pinned to a dedicated CPU set (Linux only, requires taskset).
+// If no CPU sets are provided, the CPUs goabs may run on (its affinity) are split evenly among the workers.
+func NewParallelRunner(base Runner, workers int, cpuSets []string) (Runner, error) {
+	r, ok := base.(*runnerWithPenalty)
+	if !ok {
+		return nil, fmt.Errorf("Runner of type %T can not be executed in parallel", base)
+	}
+
+	if len(cpuSets) > 0 {
+		if workers > len(cpuSets) {
+			return nil, fmt.Errorf("Not enough CPU sets (%d) for %d workers", len(cpuSets), workers)
+		}
+		workers = len(cpuSets)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	if !pinningSupported() {
+		fmt.Printf("CPU pinning not supported on this host, workers are not pinned\n")
+		cpuSets = make([]string, workers)
+	} else if len(cpuSets) == 0 {
+		cpus, err := affinity()
+		if err != nil {
+			return nil, err
+		}
+		sets, err := splitCPUs(cpus, workers)
+		if err != nil {
+			return nil, err
+		}
+		cpuSets = sets
+	}
+
+	ws := make([]*runnerWithPenalty, 0, workers)
+	for _, cpuSet := range cpuSets {
+		// workers share penalties and output with the base runner
+		w := *r
+		w.cpuSet = cpuSet
+		ws = append(ws, &w)
+	}
+
+	return &parallelRunner{
+		base:    r,
+		workers: ws,
+	}, nil
+}
+
+func pinningSupported() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := exec.LookPath(cmdTaskset)
+	return err == nil
+}
+
+// affinity returns the CPUs goabs may run on
+func affinity() ([]int, error) {
+	out, err := exec.Command(cmdTaskset, cmdArgsCPUList, cmdArgsPID, strconv.Itoa(os.Getpid())).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read CPU affinity: %v\n%s", err, out)
+	}
+	// e.g., pid 42's current affinity list: 0-3,6
+	i := strings.LastIndex(string(out), ":")
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid CPU affinity: %s", out)
+	}
+	return parseCPUList(strings.TrimSpace(string(out[i+1:])))
+}
+
+// parseCPUList parses a CPU list in taskset format (e.g., 0-3,6)
+func parseCPUList(list string) ([]int, error) {
+	cpus := []int{}
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CPU list '%s'", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("Invalid CPU list '%s'", list)
+			}
+		}
+		for c := first; c <= last; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
+
+// splitCPUs splits cpus into n CPU lists of consecutive CPUs in taskset format (e.g., 0-3);
+// the lists of the first len(cpus) % n workers contain one additional CPU
+func splitCPUs(cpus []int, n int) ([]string, error) {
+	size := len(cpus) / n
+	if size == 0 {
+		return nil, fmt.Errorf("Not enough CPUs (%d) for %d workers", len(cpus), n)
+	}
+	rest := len(cpus) % n
+	sets := make([]string, 0, n)
+	first := 0
+	for i := 0; i < n; i++ {
+		last := first + size
+		if i < rest {
+			last++
+		}
+		sets = append(sets, cpuList(cpus[first:last]))
+		first = last
+	}
+	return sets, nil
+}
+
+// cpuList formats cpus in taskset format, ranges of consecutive CPUs are merged
+func cpuList(cpus []int) string {
+	ranges := []string{}
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(cpus[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+type parallelRunner struct {
+	base    *runnerWithPenalty
+	workers []*runnerWithPenalty
+}
+
+func (r *parallelRunner) Run(ctx context.Context, run int, v Variant) (int, error) {
+	r.base.retainBinaries(v)
+	if r.base.runDuration != 0 {
+		return r.RunUntil(ctx, run, v, runDeadline(ctx, r.base.runDuration))
+	}
+	return r.RunSuite(ctx, run, 0, v, nil)
+}
+
+func (r *parallelRunner) RunUntil(ctx context.Context, run int, v Variant, done <-chan struct{}) (int, error) {
+	benchCount := 0
+	for suiteExec := 0; true; suiteExec++ {
+		executed, err := r.RunSuite(ctx, run, suiteExec, v, done)
+		benchCount += executed
+		if err != nil {
+			return benchCount, err
+		}
+
+		select {
+		case <-done:
+			return benchCount, nil
+		default:
+		}
+	}
+	return benchCount, nil
+}
+
+// RunSuite executes every benchmark once, distributed over the workers.
+// Workers stop taking new benchmarks when ctx or done (if not nil) is done.
+func (r *parallelRunner) RunSuite(ctx context.Context, run int, suiteExec int, v Variant, done <-chan struct{}) (int, error) {
+	suite := r.base.suite(run, suiteExec, v.Test)
+	jobs := make([]job, 0, len(suite))
+	for _, bench := range suite {
+		jobs = append(jobs, job{bench: bench, v: v})
+	}
+	return r.runJobs(ctx, run, suiteExec, jobs, done)
+}
+
+// RunInterleaved executes every benchmark for all variants (in random order) before the next benchmark, distributed over the workers.
+func (r *parallelRunner) RunInterleaved(ctx context.Context, run int, vs []Variant) (int, error) {
+	r.base.retainBinaries(vs...)
+	jobs := []job{}
+	for _, bench := range r.base.suite(run, 0, interleavedTest) {
+		for _, v := range r.base.interleaved(run, bench, vs) {
+			jobs = append(jobs, job{bench: bench, v: v})
+		}
+	}
+	return r.runJobs(ctx, run, 0, jobs, nil)
+}
+
+// job is a benchmark execution for a variant
+type job struct {
+	bench data.Function
+	v     Variant
+}
+
+func (r *parallelRunner) runJobs(ctx context.Context, run int, suiteExec int, js []job, done <-chan struct{}) (int, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for _, j := range js {
+			select {
+			case jobs <- j:
+			case <-wctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var l sync.Mutex
+	var firstErr error
+	benchCount := 0
+
+	var wg sync.WaitGroup
+	for _, w := range r.workers {
+		wg.Add(1)
+		go func(w *runnerWithPenalty) {
+			defer wg.Done()
+			for j := range jobs {
+				executed, err := w.RunBenchmark(wctx, j.bench, run, suiteExec, j.v)
+
+				l.Lock()
+				benchCount += executed
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				l.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return benchCount, err
+	}
+	return benchCount, firstErr
+}
@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitCPUs(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		n    int
+		exp  []string
+	}{
+		{[]int{0, 1, 2, 3, 4, 5, 6, 7}, 2, []string{"0-3", "4-7"}},
+		{[]int{0, 1, 2, 3, 4, 5, 6}, 3, []string{"0-2", "3-4", "5-6"}},
+		{[]int{0, 1, 2}, 3, []string{"0", "1", "2"}},
+		{[]int{0, 1, 4, 5, 6, 9}, 2, []string{"0-1,4", "5-6,9"}},
+		{[]int{2, 3}, 1, []string{"2-3"}},
+		{[]int{0, 1}, 3, nil},
+	}
+	for _, test := range tests {
+		sets, err := splitCPUs(test.cpus, test.n)
+		if test.exp == nil {
+			if err == nil {
+				t.Errorf("Expected error for %d workers on CPUs %v", test.n, test.cpus)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(sets, test.exp) {
+			t.Errorf("Unexpected CPU sets of %d workers on CPUs %v\nexpected: %v\nwas:      %v (%v)", test.n, test.cpus, test.exp, sets, err)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := map[string][]int{
+		"0":       {0},
+		"0-3":     {0, 1, 2, 3},
+		"0-1,4,6": {0, 1, 4, 6},
+		"3-1":     nil,
+		"a":       nil,
+	}
+	for list, exp := range tests {
+		cpus, err := parseCPUList(list)
+		if exp == nil {
+			if err == nil {
+				t.Errorf("Expected error for CPU list '%s'", list)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(cpus, exp) {
+			t.Errorf("Unexpected CPUs of '%s': expected %v, was %v (%v)", list, exp, cpus, err)
+		}
+	}
+}
+
+func TestAffinity(t *testing.T) {
+	if !pinningSupported() {
+		t.Skip("CPU pinning not supported")
+	}
+	cpus, err := affinity()
+	if err != nil {
+		t.Fatalf("Could not read affinity: %v", err)
+	}
+	// the runtime respects the affinity
+	if len(cpus) != runtime.NumCPU() {
+		t.Errorf("Expected %d CPUs, was %v", runtime.NumCPU(), cpus)
+	}
+}
+
+func TestNewParallelRunner(t *testing.T) {
+	tests := []struct {
+		workers int
+		cpuSets []string
+		exp     int // workers, -1 if invalid
+	}{
+		{0, []string{"0", "1"}, 2},
+		{2, []string{"0", "1", "2"}, 3},
+		{3, []string{"0", "1"}, -1},
+	}
+	for _, test := range tests {
+		r, err := NewParallelRunner(&runnerWithPenalty{}, test.workers, test.cpuSets)
+		if test.exp < 0 {
+			if err == nil {
+				t.Errorf("Expected error for %d workers on CPU sets %v", test.workers, test.cpuSets)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Could not create runner for %d workers on CPU sets %v: %v", test.workers, test.cpuSets, err)
+			continue
+		}
+		ws := r.(*parallelRunner).workers
+		if len(ws) != test.exp {
+			t.Errorf("Expected %d workers, was %d", test.exp, len(ws))
+		}
+		if pinningSupported() {
+			for i, w := range ws {
+				if w.cpuSet != test.cpuSets[i] {
+					t.Errorf("Expected worker %d on CPU set %s, was %s", i, test.cpuSets[i], w.cpuSet)
+				}
+			}
+		}
+	}
+
+	if _, err := NewParallelRunner(nil, 2, nil); err == nil {
+		t.Errorf("Expected error for runner that can not be executed in parallel")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/executil"
 	"github.com/sealuzh/goabs/utils/fsutil"
 )
 
@@ -37,6 +38,11 @@ func MatchingFunctions(rootPath, benchRegex string) (data.PackageMap, error) {
 			return filepath.SkipDir
 		}
 
+		if path != rootPath && executil.IsModuleRoot(path) {
+			// nested modules are not built as part of the project
+			return filepath.SkipDir
+		}
+
 		pkg := strings.Replace(path, rootPath, "", -1)
 
 		fileInfos, err := ioutil.ReadDir(path)
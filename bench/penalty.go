@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"sync"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const defaultPenaltyStrikes = 3
+
+// penalties holds the benchmarks that are not executed anymore; safe for concurrent use
+type penalties struct {
+	l       sync.Mutex
+	policy  data.PenaltyPolicy
+	strikes int
+	m       map[string]int // run of the penalty by benchmark
+	fails   map[string]int // failures by benchmark
+}
+
+func newPenalties(policy data.PenaltyPolicy, strikes int) *penalties {
+	if policy == "" {
+		policy = data.PermanentPenalty
+	}
+	switch {
+	case policy != data.StrikesPenalty:
+		strikes = 1
+	case strikes <= 0:
+		strikes = defaultPenaltyStrikes
+	}
+
+	return &penalties{
+		policy:  policy,
+		strikes: strikes,
+		m:       make(map[string]int),
+		fails:   make(map[string]int),
+	}
+}
+
+// add penalises bench in run
+func (p *penalties) add(bench string, run int) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.m[bench] = run
+}
+
+// addFailures restores n failures of bench
+func (p *penalties) addFailures(bench string, n int) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.fails[bench] += n
+}
+
+// fail records a failure of bench in run and returns whether bench got penalised
+func (p *penalties) fail(bench string, run int) bool {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.fails[bench]++
+	if p.policy != data.RetryPenalty && p.fails[bench] < p.strikes {
+		return false
+	}
+	p.m[bench] = run
+	return true
+}
+
+// has reports whether bench is penalised in run
+func (p *penalties) has(bench string, run int) bool {
+	p.l.Lock()
+	defer p.l.Unlock()
+	penaltyRun, ok := p.m[bench]
+	if !ok {
+		return false
+	}
+	// retry penalties expire with the run
+	return p.policy != data.RetryPenalty || penaltyRun == run
+}
@@ -0,0 +1,64 @@
+package bench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func TestPenaltyPolicies(t *testing.T) {
+	p := newPenalties("", 0)
+	if !p.fail("a", 0) || !p.has("a", 1) {
+		t.Errorf("Expected permanent penalty after first failure")
+	}
+
+	p = newPenalties(data.StrikesPenalty, 2)
+	if p.fail("a", 0) || p.has("a", 0) {
+		t.Errorf("Expected no penalty after first strike")
+	}
+	if !p.fail("a", 1) || !p.has("a", 2) {
+		t.Errorf("Expected permanent penalty after second strike")
+	}
+
+	p = newPenalties(data.RetryPenalty, 0)
+	if !p.fail("a", 0) || !p.has("a", 0) {
+		t.Errorf("Expected penalty in the run of the failure")
+	}
+	if p.has("a", 1) {
+		t.Errorf("Expected penalty to expire with the run")
+	}
+}
+
+func TestPenaltiesRestoredFromJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-penalty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.journal")
+	j, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+	j.Fail(0, 0, Baseline, "a")
+	j.Fail(1, 0, Baseline, "a")
+	j.Penalise(1, 0, Baseline, "a")
+	j.Fail(1, 0, Baseline, "b")
+	j.Close()
+
+	j, err = OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("Could not resume journal: %v", err)
+	}
+	defer j.Close()
+	if f := j.Failures(); f["a"] != 2 || f["b"] != 1 {
+		t.Errorf("Unexpected failures: %v", f)
+	}
+	if p := j.Penalised(); len(p) != 1 || p["a"] != 1 {
+		t.Errorf("Unexpected penalties: %v", p)
+	}
+}
@@ -0,0 +1,260 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Baseline is the test name of benchmark executions against the unaltered project.
+const Baseline = "Baseline"
+
+const (
+	// iterations of warm-up records are prefixed in the execution identifier (e.g., 0-0-0-w1)
+	csvWarmupPrefix = "w"
+	// invocations column of failed benchmark executions
+	csvFailed = "failed"
+
+	csvColsRuntime = 5
+	csvColsMem     = 7
+	// result files written before invocation counts were recorded
+	csvColsLegacy = 4
+)
+
+// Record is a single benchmark result as written to the result file.
+type Record struct {
+	Run         int
+	SuiteExec   int
+	BenchExec   int
+	Test        string
+	Benchmark   string
+	Invocations int
+	Metrics     map[string]float64 // metric value by unit (e.g., ns/op)
+	Iteration   int                // iteration of the benchmark within its execution (-count), warm-ups included
+	Warmup      bool
+}
+
+// ReadCSV reads the results written by a benchmark runner.
+// Failed benchmark executions are skipped.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.FieldsPerRecord = -1
+
+	ret := []Record{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(rec) > 3 && rec[3] == csvFailed {
+			continue
+		}
+
+		r, err := parseRecord(rec)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse result line %d: %v", line, err)
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+func parseRecord(rec []string) (Record, error) {
+	l := len(rec)
+	if l != csvColsLegacy && l != csvColsRuntime && l != csvColsMem {
+		return Record{}, fmt.Errorf("Invalid number of columns %d", l)
+	}
+
+	// run-suiteExec-benchExec[-iteration]
+	ids := strings.Split(rec[0], "-")
+	if len(ids) != 3 && len(ids) != 4 {
+		return Record{}, fmt.Errorf("Invalid execution identifier '%s'", rec[0])
+	}
+	var warmup bool
+	if len(ids) == 4 && strings.HasPrefix(ids[3], csvWarmupPrefix) {
+		warmup = true
+		ids[3] = strings.TrimPrefix(ids[3], csvWarmupPrefix)
+	}
+	var execs [4]int
+	for i, id := range ids {
+		v, err := strconv.Atoi(id)
+		if err != nil {
+			return Record{}, fmt.Errorf("Invalid execution identifier '%s'", rec[0])
+		}
+		execs[i] = v
+	}
+
+	r := Record{
+		Run:       execs[0],
+		SuiteExec: execs[1],
+		BenchExec: execs[2],
+		Iteration: execs[3],
+		Warmup:    warmup,
+		Test:      rec[1],
+		Benchmark: rec[2],
+		Metrics:   map[string]float64{},
+	}
+
+	rtCol := 4
+	if l == csvColsLegacy {
+		rtCol = 3
+	} else {
+		ivs, err := strconv.Atoi(rec[3])
+		if err != nil {
+			return Record{}, fmt.Errorf("Invalid invocation count '%s'", rec[3])
+		}
+		r.Invocations = ivs
+	}
+
+	units := []string{timeUnit}
+	if l == csvColsMem {
+		units = append(units, bytesUnit, allocsUnit)
+	}
+	for i, unit := range units {
+		v, err := strconv.ParseFloat(rec[rtCol+i], 64)
+		if err != nil {
+			return Record{}, fmt.Errorf("Invalid %s value '%s'", unit, rec[rtCol+i])
+		}
+		r.Metrics[unit] = v
+	}
+	return r, nil
+}
+
+// ReadJSONL reads the results written by a JSON Lines sink.
+// Failed benchmark executions are skipped.
+func ReadJSONL(r io.Reader) ([]Record, error) {
+	var ms []jsonMeasurement
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var m struct {
+			jsonMeasurement
+			Failure string `json:"failure"`
+		}
+		err := json.Unmarshal(s.Bytes(), &m)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse result line %d: %v", line, err)
+		}
+		if m.Failure != "" {
+			continue
+		}
+		ms = append(ms, m.jsonMeasurement)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return groupMeasurements(ms), nil
+}
+
+// ReadSQLite reads the results of experiment from a SQLite database written by a SQLite sink.
+// If experiment is empty, the database must contain a single experiment.
+// It requires the sqlite3 command line shell.
+func ReadSQLite(path, experiment string) ([]Record, error) {
+	// sqlite3 creates missing databases
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("Could not open results: %v", err)
+	}
+
+	query := "SELECT experiment, run, suite_exec, bench_exec, test, benchmark, invocations, metric, value, iteration, warmup FROM results"
+	if experiment != "" {
+		query += " WHERE experiment = " + sqlString(experiment)
+	}
+	query += " ORDER BY rowid;"
+
+	c := exec.Command(cmdSQLite, cmdArgsSQLiteBail, cmdArgsSQLiteCSV, path, query)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read results of %s: %v\n%s", path, err, stderr.String())
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse results of %s: %v", path, err)
+	}
+
+	ms := make([]jsonMeasurement, 0, len(rows))
+	for i, row := range rows {
+		if len(row) != 11 {
+			return nil, fmt.Errorf("Invalid number of columns %d in result row %d", len(row), i+1)
+		}
+		if experiment == "" {
+			experiment = row[0]
+		} else if row[0] != experiment {
+			return nil, fmt.Errorf("Multiple experiments in %s ('%s' and '%s'), select one with 'experiment'", path, experiment, row[0])
+		}
+
+		var ints [6]int
+		for j, col := range []int{1, 2, 3, 6, 9, 10} {
+			ints[j], err = strconv.Atoi(row[col])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value '%s' in result row %d", row[col], i+1)
+			}
+		}
+		v, err := strconv.ParseFloat(row[8], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value '%s' in result row %d", row[8], i+1)
+		}
+		ms = append(ms, jsonMeasurement{
+			Run:         ints[0],
+			SuiteExec:   ints[1],
+			BenchExec:   ints[2],
+			Test:        row[4],
+			Benchmark:   row[5],
+			Invocations: ints[3],
+			Metric:      row[7],
+			Value:       v,
+			Iteration:   ints[4],
+			Warmup:      ints[5] != 0,
+		})
+	}
+	return groupMeasurements(ms), nil
+}
+
+// groupMeasurements merges the metrics of the same benchmark iteration into records, in order of their first measurement
+func groupMeasurements(ms []jsonMeasurement) []Record {
+	type key struct {
+		run, suiteExec, benchExec, iteration int
+		warmup                               bool
+		test, benchmark                      string
+	}
+
+	ret := []Record{}
+	idx := make(map[key]int)
+	for _, m := range ms {
+		k := key{m.Run, m.SuiteExec, m.BenchExec, m.Iteration, m.Warmup, m.Test, m.Benchmark}
+		i, ok := idx[k]
+		if !ok {
+			i = len(ret)
+			idx[k] = i
+			ret = append(ret, Record{
+				Run:         m.Run,
+				SuiteExec:   m.SuiteExec,
+				BenchExec:   m.BenchExec,
+				Test:        m.Test,
+				Benchmark:   m.Benchmark,
+				Invocations: m.Invocations,
+				Metrics:     map[string]float64{},
+				Iteration:   m.Iteration,
+				Warmup:      m.Warmup,
+			})
+		}
+		ret[i].Metrics[m.Metric] = m.Value
+	}
+	return ret
+}
@@ -1,9 +1,13 @@
 package bench
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -12,76 +16,164 @@ const (
 	allocsUnit = "allocs/op"
 )
 
-type resultNotParsable error
-
-type result struct {
-	Invocations int     // invocations per benchmark execution
-	Runtime     float32 // unit: ns/op
-	Memory      int     // unit: B/op
-	Allocations int     // unit: allocs/op
+type resultNotParsable struct {
+	err error
 }
 
-type resultParser interface {
-	parse(string) ([]result, error)
+func (e resultNotParsable) Error() string {
+	return e.err.Error()
 }
 
-type rtResultParser struct{}
+// Output is the parsed output of a go test -bench execution
+// (see https://golang.org/design/14313-benchmark-format).
+type Output struct {
+	Config  map[string]string // configuration keys of the output (e.g., goos, goarch, pkg, cpu)
+	Results []Result
+}
 
-func (p rtResultParser) parse(s string) ([]result, error) {
-	return parse(s, false)
+// Result is a single benchmark result line.
+type Result struct {
+	Name        string // benchmark name including sub-benchmarks without GOMAXPROCS suffix (e.g., BenchmarkX/size=1024)
+	Procs       int    // GOMAXPROCS of the execution
+	Invocations int
+	Values      []Value
+	Config      map[string]string // configuration in effect for this result
 }
 
-type memResultParser struct{}
+type Value struct {
+	Value float64
+	Unit  string
+}
 
-func (p memResultParser) parse(s string) ([]result, error) {
-	return parse(s, true)
+// Metrics returns the values of the result by unit.
+func (r Result) Metrics() map[string]float64 {
+	m := make(map[string]float64, len(r.Values))
+	for _, v := range r.Values {
+		m[v.Unit] = v.Value
+	}
+	return m
 }
 
-func parse(s string, mem bool) ([]result, error) {
-	resArr := strings.Fields(s)
+// ParseOutput parses the output of a go test -bench execution with GOMAXPROCS procs (go test -cpu).
+// The names of benchmarks executed with more than one procs are suffixed with -procs, which is removed;
+// as sub-benchmark names may end with -<number> too (e.g., BenchmarkX/size-1024), no other suffix is removed.
+// Lines that are neither configuration nor benchmark result lines are ignored.
+func ParseOutput(r io.Reader, procs int) (Output, error) {
+	out := Output{
+		Config:  map[string]string{},
+		Results: []Result{},
+	}
+	// configuration is shared by results until it changes
+	config := map[string]string{}
+	configChanged := false
 
-	ret := []result{}
-	emptyResult := []result{}
-	curr := result{}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		l := s.Text()
 
-	for i, f := range resArr {
-		switch f {
-		case timeUnit:
-			// parse timeunit
-			rt, err := strconv.ParseFloat(resArr[i-1], 32)
-			if err != nil {
-				return emptyResult, resultNotParsable(fmt.Errorf("Could not parse benchmark result. Error: %v", err))
+		if key, value, ok := parseConfigLine(l); ok {
+			if !configChanged {
+				config = copyConfig(config)
+				configChanged = true
 			}
-			curr.Runtime = float32(rt)
+			config[key] = value
+			out.Config[key] = value
+			continue
+		}
 
-			// parse invocation cound
-			ivs, err := strconv.ParseInt(resArr[i-2], 10, 64)
-			if err != nil {
-				return emptyResult, resultNotParsable(fmt.Errorf("Could not parse invocation count. Error: %v", err))
-			}
-			curr.Invocations = int(ivs)
+		if res, ok := parseResultLine(l, procs); ok {
+			res.Config = config
+			configChanged = false
+			out.Results = append(out.Results, res)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Output{}, err
+	}
+	return out, nil
+}
 
-			// add parsed result to return slice (no further results for that execution)
-			if !mem {
-				ret = append(ret, curr)
-			}
-		case bytesUnit:
-			b, err := strconv.Atoi(resArr[i-1])
-			if err != nil {
-				return emptyResult, resultNotParsable(fmt.Errorf("Could not parse benchmark result. Error: %v", err))
-			}
-			curr.Memory = b
-		case allocsUnit:
-			a, err := strconv.Atoi(resArr[i-1])
-			if err != nil {
-				return emptyResult, resultNotParsable(fmt.Errorf("Could not parse benchmark result. Error: %v", err))
-			}
-			curr.Allocations = a
-			// last result for this execution -> add to return slice
-			if mem {
-				ret = append(ret, curr)
-			}
+func parseOutput(s string, procs int) (Output, error) {
+	out, err := ParseOutput(strings.NewReader(s), procs)
+	if err != nil {
+		return out, err
+	}
+	if len(out.Results) == 0 {
+		return out, resultNotParsable{err: fmt.Errorf("No benchmark results in output")}
+	}
+	return out, nil
+}
+
+func copyConfig(c map[string]string) map[string]string {
+	ret := make(map[string]string, len(c)+1)
+	for k, v := range c {
+		ret[k] = v
+	}
+	return ret
+}
+
+// parseConfigLine parses lines of the form "key: value",
+// where key begins with a lower case character and contains neither spaces nor upper case characters
+func parseConfigLine(l string) (string, string, bool) {
+	i := strings.Index(l, ":")
+	if i <= 0 || i+1 >= len(l) || (l[i+1] != ' ' && l[i+1] != '\t') {
+		return "", "", false
+	}
+	key := l[:i]
+	first, _ := utf8.DecodeRuneInString(key)
+	if !unicode.IsLower(first) {
+		return "", "", false
+	}
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsUpper(r) {
+			return "", "", false
 		}
 	}
-	return ret, nil
+	return key, strings.TrimSpace(l[i+1:]), true
+}
+
+// parseResultLine parses lines of the form "BenchmarkName-procs N value unit [value unit]...".
+// Lines not of this form (e.g., output of the benchmark itself) are not result lines.
+func parseResultLine(l string, procs int) (Result, bool) {
+	if !strings.HasPrefix(l, benchFuncPrefix) {
+		return Result{}, false
+	}
+	// the name must not continue with a lower case character (e.g., Benchmarking)
+	if next, _ := utf8.DecodeRuneInString(l[len(benchFuncPrefix):]); unicode.IsLower(next) {
+		return Result{}, false
+	}
+
+	fields := strings.Fields(l)
+	// name, invocations, and value-unit pairs
+	if len(fields) < 4 || len(fields)%2 != 0 {
+		return Result{}, false
+	}
+	ivs, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Result{}, false
+	}
+
+	res := Result{
+		Name:        trimProcs(fields[0], procs),
+		Procs:       procs,
+		Invocations: ivs,
+		Values:      make([]Value, 0, (len(fields)-2)/2),
+	}
+	for i := 2; i < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return Result{}, false
+		}
+		res.Values = append(res.Values, Value{Value: v, Unit: fields[i+1]})
+	}
+	return res, true
+}
+
+// trimProcs removes the GOMAXPROCS suffix (-procs) from a benchmark name, which go test omits if procs is 1
+func trimProcs(name string, procs int) string {
+	if procs == 1 {
+		return name
+	}
+	return strings.TrimSuffix(name, "-"+strconv.Itoa(procs))
 }
@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: github.com/sealuzh/goabs/sample
+cpu: Intel(R) Xeon(R) CPU @ 2.20GHz
+BenchmarkFib-8             	   69556	     16920 ns/op
+BenchmarkFib-8             	   70126	     16830 ns/op
+BenchmarkSizes/size=1024-8 	  100000	     10432 ns/op	  98.15 MB/s	    1024 B/op	       1 allocs/op
+BenchmarkSizes/size=2048-8 	   50000	     20981 ns/op	  97.61 MB/s	    2048 B/op	       1 allocs/op
+BenchmarkCustom            	    1000	   1000000 ns/op	        42.5 hits/op
+Benchmarking is not a result line
+BenchmarkLog 1000 is printed by the benchmark
+PASS
+ok  	github.com/sealuzh/goabs/sample	5.123s
+`
+
+func TestParseOutput(t *testing.T) {
+	out, err := ParseOutput(strings.NewReader(benchOutput), 8)
+	if err != nil {
+		t.Fatalf("Could not parse output: %v", err)
+	}
+
+	expConfig := map[string]string{
+		"goos":   "linux",
+		"goarch": "amd64",
+		"pkg":    "github.com/sealuzh/goabs/sample",
+		"cpu":    "Intel(R) Xeon(R) CPU @ 2.20GHz",
+	}
+	for k, v := range expConfig {
+		if out.Config[k] != v {
+			t.Errorf("Unexpected config %s: expected '%s', was '%s'", k, v, out.Config[k])
+		}
+	}
+
+	expected := []struct {
+		name    string
+		procs   int
+		ivs     int
+		metrics map[string]float64
+	}{
+		{"BenchmarkFib", 8, 69556, map[string]float64{"ns/op": 16920}},
+		{"BenchmarkFib", 8, 70126, map[string]float64{"ns/op": 16830}},
+		{"BenchmarkSizes/size=1024", 8, 100000, map[string]float64{"ns/op": 10432, "MB/s": 98.15, "B/op": 1024, "allocs/op": 1}},
+		{"BenchmarkSizes/size=2048", 8, 50000, map[string]float64{"ns/op": 20981, "MB/s": 97.61, "B/op": 2048, "allocs/op": 1}},
+		{"BenchmarkCustom", 8, 1000, map[string]float64{"ns/op": 1000000, "hits/op": 42.5}},
+	}
+	if len(out.Results) != len(expected) {
+		t.Fatalf("Unexpected number of results: expected %d, was %d", len(expected), len(out.Results))
+	}
+	for i, exp := range expected {
+		res := out.Results[i]
+		if res.Name != exp.name || res.Procs != exp.procs || res.Invocations != exp.ivs {
+			t.Errorf("Unexpected result %d: expected %s-%d %d, was %s-%d %d", i, exp.name, exp.procs, exp.ivs, res.Name, res.Procs, res.Invocations)
+		}
+		m := res.Metrics()
+		if len(m) != len(exp.metrics) {
+			t.Errorf("Unexpected metrics of result %d: expected %v, was %v", i, exp.metrics, m)
+		}
+		for unit, v := range exp.metrics {
+			if m[unit] != v {
+				t.Errorf("Unexpected %s of result %d: expected %f, was %f", unit, i, v, m[unit])
+			}
+		}
+		if res.Config["pkg"] != expConfig["pkg"] {
+			t.Errorf("Result %d misses configuration", i)
+		}
+	}
+}
+
+func TestParseOutputProcs(t *testing.T) {
+	tests := []struct {
+		out   string
+		procs int
+		name  string
+	}{
+		// go test omits the suffix with GOMAXPROCS 1
+		{"BenchmarkX/size-1024 100 12.5 ns/op\n", 1, "BenchmarkX/size-1024"},
+		{"BenchmarkX-1 100 12.5 ns/op\n", 1, "BenchmarkX-1"},
+		{"BenchmarkX/size-1024-4 100 12.5 ns/op\n", 4, "BenchmarkX/size-1024"},
+		{"BenchmarkX/size-1024-4 100 12.5 ns/op\n", 8, "BenchmarkX/size-1024-4"},
+	}
+	for _, test := range tests {
+		out, err := parseOutput(test.out, test.procs)
+		if err != nil {
+			t.Fatalf("Could not parse output: %v", err)
+		}
+		if res := out.Results[0]; res.Name != test.name || res.Procs != test.procs {
+			t.Errorf("Unexpected result of '%s' with GOMAXPROCS %d: expected %s-%d, was %s-%d", strings.TrimSpace(test.out), test.procs, test.name, test.procs, res.Name, res.Procs)
+		}
+	}
+}
+
+func TestParseOutputInvalid(t *testing.T) {
+	_, err := parseOutput("FAIL\tgithub.com/sealuzh/goabs/sample [build failed]\n", 8)
+	if _, ok := err.(resultNotParsable); !ok {
+		t.Errorf("Expected resultNotParsable for output without results, was %v", err)
+	}
+
+	_, err = parseOutput("BenchmarkX-8 100 12.5 ns/op 3\n", 8)
+	if _, ok := err.(resultNotParsable); !ok {
+		t.Errorf("Expected resultNotParsable for value without unit, was %v", err)
+	}
+}
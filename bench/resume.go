@@ -0,0 +1,122 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+// DropIncomplete removes the results and failures of the result file path (CSV or JSON Lines format)
+// that do not belong to a benchmark execution completed according to j, e.g., of benchmarks that were interrupted,
+// executed concurrently, or failed before the experiment was interrupted.
+// Incomplete lines (e.g., if goabs was killed while writing them) are removed as well.
+// Results of SQLite databases are removed by NewSQLiteSink.
+func DropIncomplete(path string, format data.OutFormat, j *Journal) error {
+	in, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Could not read results: %v", err)
+	}
+
+	var out bytes.Buffer
+	switch format {
+	case data.JSONLFormat:
+		err = dropIncompleteJSONL(bytes.NewReader(in), &out, j)
+	case data.SQLiteFormat:
+		return fmt.Errorf("Results of format '%s' are removed by the sink", format)
+	default:
+		err = dropIncompleteCSV(bytes.NewReader(in), &out, j)
+	}
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, out.Bytes(), 0666)
+	if err != nil {
+		return fmt.Errorf("Could not write results: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func dropIncompleteCSV(r io.Reader, w io.Writer, j *Journal) error {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.FieldsPerRecord = -1
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if _, ok := err.(*csv.ParseError); ok {
+			// the last line is incomplete if goabs was killed while writing it
+			fmt.Printf("Ignoring invalid result line: %v\n", err)
+			break
+		} else if err != nil {
+			return err
+		}
+		if len(rec) < csvColsLegacy {
+			continue
+		}
+
+		// run-suiteExec-benchExec[-iteration]
+		ids := strings.Split(rec[0], "-")
+		if len(ids) < 3 {
+			continue
+		}
+		run, err := strconv.Atoi(ids[0])
+		if err != nil {
+			continue
+		}
+		suiteExec, err := strconv.Atoi(ids[1])
+		if err != nil {
+			continue
+		}
+		if !j.Completed(run, suiteExec, rec[1], rec[2]) {
+			continue
+		}
+
+		err = cw.Write(rec)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func dropIncompleteJSONL(r io.Reader, w io.Writer, j *Journal) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		// measurements and failures
+		var m struct {
+			Run       int    `json:"run"`
+			SuiteExec int    `json:"suiteExec"`
+			Test      string `json:"test"`
+			Benchmark string `json:"benchmark"`
+		}
+		err := json.Unmarshal(s.Bytes(), &m)
+		if err != nil || !j.Completed(m.Run, m.SuiteExec, m.Test, m.Benchmark) {
+			continue
+		}
+
+		_, err = fmt.Fprintf(w, "%s\n", s.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
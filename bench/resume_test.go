@@ -0,0 +1,219 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const resumeBenchSrc = `package resume
+
+import "testing"
+
+func BenchmarkA(b *testing.B) { work(b) }
+
+func BenchmarkB(b *testing.B) { work(b) }
+
+func BenchmarkC(b *testing.B) { work(b) }
+
+func BenchmarkD(b *testing.B) { work(b) }
+
+func work(b *testing.B) {
+	s := 0
+	for i := 0; i < b.N; i++ {
+		s += i
+	}
+	_ = s
+}
+`
+
+// interruptingSink cancels the experiment after n writes
+type interruptingSink struct {
+	ResultSink
+	l      sync.Mutex
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *interruptingSink) Write(r Record) error {
+	s.l.Lock()
+	s.n--
+	if s.n == 0 {
+		s.cancel()
+	}
+	s.l.Unlock()
+	return s.ResultSink.Write(r)
+}
+
+func TestParallelResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "project")
+	err = os.MkdirAll(project, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(project, "go.mod"), []byte("module example.com/resume\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(project, "resume_test.go"), []byte(resumeBenchSrc), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := data.File{}
+	for _, n := range []string{"A", "B", "C", "D"} {
+		fs = append(fs, data.Function{Pkg: "", File: "resume_test.go", Name: "Benchmark" + n})
+	}
+	benchs := data.PackageMap{"": data.FileMap{"resume_test.go": fs}}
+
+	out := filepath.Join(dir, "out.csv")
+	run := func(ctx context.Context, resume bool, wrap func(ResultSink) ResultSink) error {
+		j, err := OpenJournal(out+".journal", resume)
+		if err != nil {
+			return err
+		}
+		defer j.Close()
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if resume {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			err = DropIncomplete(out, data.CSVFormat, j)
+			if err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(out, flags, 0666)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		binDir := filepath.Join(dir, fmt.Sprintf("bin-%t", resume))
+		r, err := NewRunner(RunnerConfig{
+			GoRoot:                runtime.GOROOT(),
+			ProjectRoot:           project,
+			Benchs:                benchs,
+			MeasurementIterations: 1,
+			Timeout:               time.Minute,
+			BenchTime:             time.Millisecond,
+			BenchDuration:         300 * time.Millisecond,
+			Out:                   wrap(NewCSVSink(f, false)),
+			Journal:               j,
+			Penalty:               data.PermanentPenalty,
+			BinDir:                binDir,
+		})
+		if err != nil {
+			return err
+		}
+		// workers are not pinned
+		r, err = NewParallelRunner(r, 2, []string{"", ""})
+		if err != nil {
+			return err
+		}
+		_, err = r.Run(ctx, 0, Variant{Test: Baseline})
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = run(ctx, false, func(s ResultSink) ResultSink {
+		return &interruptingSink{ResultSink: s, n: 5, cancel: cancel}
+	})
+	if err == nil {
+		t.Fatalf("Expected interrupted experiment")
+	}
+
+	err = run(context.Background(), true, func(s ResultSink) ResultSink { return s })
+	if err != nil {
+		t.Fatalf("Could not resume experiment: %v", err)
+	}
+
+	res, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := ReadCSV(bytes.NewReader(res))
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	seen := map[string]struct{}{}
+	benchExecs := map[string]int{}
+	for _, r := range rs {
+		k := fmt.Sprintf("%d-%d-%d-%d;%s;%s", r.Run, r.SuiteExec, r.BenchExec, r.Iteration, r.Test, r.Benchmark)
+		if _, ok := seen[k]; ok {
+			t.Errorf("Duplicate result %s", k)
+		}
+		seen[k] = struct{}{}
+		benchExecs[r.Benchmark]++
+	}
+	for _, b := range fs {
+		if benchExecs[filepath.Join(b.File, b.Name)] == 0 {
+			t.Errorf("No results of %s:\n%s", b.Name, strings.TrimSpace(string(res)))
+		}
+	}
+}
+
+func TestDropIncompleteJSONL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j, err := OpenJournal(filepath.Join(dir, "out.jsonl.journal"), false)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+	defer j.Close()
+	j.Complete(1, 2, Baseline, "pkg/a_test.go::BenchmarkA")
+
+	var buf bytes.Buffer
+	s := NewJSONLSink(&buf)
+	b := testRecord()
+	b.Benchmark = "pkg/a_test.go/BenchmarkB"
+	s.Write(b)
+	s.Write(testRecord())
+	s.(FailureSink).WriteFailure(Failure{Run: 1, SuiteExec: 2, Test: Baseline, Benchmark: b.Benchmark, Reason: PanicFailure})
+	// goabs was killed while writing the last line
+	buf.WriteString(`{"run":1,"suiteExec":2,"test":"Baseline","benchmark":"pkg/a_test.go/Bench`)
+
+	path := filepath.Join(dir, "out.jsonl")
+	err = ioutil.WriteFile(path, buf.Bytes(), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DropIncomplete(path, data.JSONLFormat, j)
+	if err != nil {
+		t.Fatalf("Could not remove incomplete results: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rs, err := ReadJSONL(f)
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	if exp := []Record{testRecord()}; !reflect.DeepEqual(rs, exp) {
+		t.Errorf("Unexpected records\nexpected: %v\nwas:      %v", exp, rs)
+	}
+}
@@ -2,13 +2,13 @@ package bench
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sealuzh/goabs/data"
@@ -18,28 +18,83 @@ import (
 const (
 	cmdName           = "go"
 	cmdArgsTest       = "test"
-	cmdArgsBench      = "-bench=^%s$"
+	cmdArgsBench      = "-bench=%s"
 	cmdArgsBenchTime  = "-benchtime=%s"
 	cmdArgsCount      = "-count=%d"
 	cmdArgsNoTests    = "-run=^$"
 	cmdArgsTimeout    = "-timeout=%s"
 	cmdArgsMem        = "-benchmem"
+	cmdArgsCPU        = "-cpu=%d"
 	cmdArgsProfileOut = "-outputdir=%s"
 	cmdArgsCPUProfile = "-cpuprofile=%s"
 	cmdArgsMemProfile = "-memprofile=%s"
 	benchRuntime      = 1
 	benchTimeoutMsg   = "*** Test killed with quit: ran too long"
+	maxFailureMessage = 4096
+	cmdTaskset        = "taskset"
+	cmdArgsCPUList    = "-c"
+	cmdArgsPID        = "-p"
+	// test of the benchmark order of interleaved runs
+	interleavedTest = "interleaved"
 )
 
 type Runner interface {
-	Run(ctx context.Context, run int, test string) (int, error)
+	Run(ctx context.Context, run int, v Variant) (int, error)
+}
+
+// InterleavedRunner is implemented by runners that can execute randomised multiple interleaved trials (RMIT),
+// i.e., every benchmark is executed for all variants before the next benchmark.
+type InterleavedRunner interface {
+	RunInterleaved(ctx context.Context, run int, vs []Variant) (int, error)
+}
+
+// Variant is a version of the project under test that benchmarks are executed against.
+type Variant struct {
+	Test      string   // Baseline or the altered function
+	BuildArgs []string // additional go build flags required to build the variant (e.g., -overlay)
+}
+
+// RunnerConfig configures a benchmark runner created by NewRunner.
+type RunnerConfig struct {
+	GoRoot      string
+	ProjectRoot string
+	Benchs      data.PackageMap
+	// WarmupIterations and MeasurementIterations per benchmark execution (at least one measurement iteration)
+	WarmupIterations      int
+	MeasurementIterations int
+	// Timeout of a benchmark execution (including the build); it is killed KillGrace after the timeout (default 2m)
+	Timeout   time.Duration
+	KillGrace time.Duration
+	BenchTime time.Duration
+	// BenchDuration executes a benchmark repeatedly for the duration (without warm-up iterations) if positive
+	BenchDuration time.Duration
+	RunDuration   time.Duration
+	BenchMem      bool
+	// Profile of the benchmark executions written to ProfileDir (default data.NoProfile)
+	Profile    data.Profile
+	ProfileDir string
+	Out        ResultSink
+	Journal    *Journal
+	// Failures records failing benchmarks (if not nil), which are penalised according to Penalty and Strikes
+	Failures *FailureLog
+	Penalty  data.PenaltyPolicy
+	Strikes  int
+	// Order of the benchmarks, seeded by the seed of OrderLog (if not nil), which records the realised order
+	Order    data.Order
+	OrderLog *OrderLog
+	Adaptive data.Adaptive
+	Warmup   data.Warmup
+	// BinDir executes the benchmarks with test binaries compiled once per package and variant into BinDir (go test -c) if not empty
+	BinDir string
 }
 
 // NewRunner creates a new benchmark runner.
 // By default it returns a penalised runner that in consecutive runs only executes successful benchmark executions.
-func NewRunner(goRoot, projectRoot string, benchs data.PackageMap, wi int, mi int, timeout, benchTime, benchDuration, runDuration time.Duration, benchMem bool, profile data.Profile, profileDir string, out csv.Writer) (Runner, error) {
+// Failing benchmarks are penalised according to the penalty policy and written to out and failures (if not nil).
+func NewRunner(c RunnerConfig) (Runner, error) {
+	wi, mi := c.WarmupIterations, c.MeasurementIterations
 	// if benchmark gets executed over time period, do not do warm-up iterations
-	if benchDuration > 0 {
+	if c.BenchDuration > 0 {
 		wi = 0
 	}
 
@@ -49,33 +104,73 @@ func NewRunner(goRoot, projectRoot string, benchs data.PackageMap, wi int, mi in
 	}
 
 	cmdCount := fmt.Sprintf(cmdArgsCount, (wi + mi))
-	cmdArgs := []string{cmdArgsTest, fmt.Sprintf(cmdArgsBenchTime, benchTime), fmt.Sprintf(cmdArgsTimeout, timeout), cmdCount, cmdArgsNoTests}
+	cmdArgs := []string{fmt.Sprintf(cmdArgsBenchTime, c.BenchTime), fmt.Sprintf(cmdArgsTimeout, c.Timeout), cmdCount, cmdArgsNoTests}
 
-	var rp resultParser = rtResultParser{}
-	if benchMem {
+	if c.BenchMem {
 		cmdArgs = append(cmdArgs, cmdArgsMem)
-		rp = memResultParser{}
+	}
+
+	killGrace := c.KillGrace
+	if killGrace == 0 {
+		killGrace = DefaultKillGrace
+	}
+
+	profile := c.Profile
+	if profile == "" {
+		profile = data.NoProfile
+	}
+
+	penalised := newPenalties(c.Penalty, c.Strikes)
+	if c.Journal != nil {
+		// failures and penalties of an interrupted experiment still apply
+		for b, n := range c.Journal.Failures() {
+			penalised.addFailures(b, n)
+		}
+		for b, run := range c.Journal.Penalised() {
+			penalised.add(b, run)
+		}
+	}
+
+	var seed int64
+	if c.OrderLog != nil {
+		seed = c.OrderLog.Seed()
+	}
+
+	var bins *binaries
+	if c.BinDir != "" {
+		bins = newBinaries(c.BinDir)
 	}
 
 	return &runnerWithPenalty{
 		defaultRunner: defaultRunner{
-			projectRoot:   projectRoot,
+			projectRoot:   c.ProjectRoot,
 			wi:            wi,
 			mi:            mi,
-			benchDuration: benchDuration,
-			runDuration:   runDuration,
-			benchMem:      benchMem,
-			resultParser:  rp,
-			out:           out,
-			benchs:        benchs,
+			benchDuration: c.BenchDuration,
+			runDuration:   c.RunDuration,
+			benchMem:      c.BenchMem,
+			out:           c.Out,
+			outLock:       &sync.Mutex{},
+			journal:       c.Journal,
+			failures:      c.Failures,
+			order:         c.Order,
+			seed:          seed,
+			orderLog:      c.OrderLog,
+			sorted:        Sorted(c.Benchs),
+			benchs:        c.Benchs,
 			profile:       profile,
-			profileDir:    profileDir,
-			env:           executil.Env(goRoot, executil.GoPath(projectRoot)),
+			profileDir:    c.ProfileDir,
+			env:           executil.ProjectEnv(c.GoRoot, c.ProjectRoot),
 			cmdCount:      cmdCount,
 			cmdArgs:       cmdArgs,
+			adaptive:      withAdaptiveDefaults(c.Adaptive, mi),
+			warmup:        withWarmupDefaults(c.Warmup),
 		},
-		penalisedBenchs: make(map[string]struct{}),
-		timeout:         timeout,
+		penalisedBenchs: penalised,
+		timeout:         c.Timeout,
+		deadline:        c.Timeout + killGrace,
+		killGrace:       killGrace,
+		binaries:        bins,
 	}, nil
 }
 
@@ -86,28 +181,64 @@ type defaultRunner struct {
 	benchDuration time.Duration
 	benchMem      bool
 	runDuration   time.Duration
-	resultParser  resultParser
-	out           csv.Writer
+	out           ResultSink
+	outLock       *sync.Mutex
+	journal       *Journal
+	failures      *FailureLog
+	order         data.Order
+	seed          int64
+	orderLog      *OrderLog
 	benchs        data.PackageMap
+	sorted        []data.Function // benchmarks in deterministic order
 	profile       data.Profile
 	profileDir    string
 	env           []string
 	cmdCount      string
 	cmdArgs       []string
+	adaptive      data.Adaptive
+	warmup        data.Warmup
 }
 
 type runnerWithPenalty struct {
 	defaultRunner
 	timeout         time.Duration
-	penalisedBenchs map[string]struct{}
+	deadline        time.Duration // hard deadline of a benchmark execution
+	killGrace       time.Duration // time between timeout and hard deadline
+	penalisedBenchs *penalties
+	binaries        *binaries // compiled test binaries; nil if benchmarks are executed with go test
+	cpuSet          string    // CPUs benchmarks are pinned to (taskset list format); empty if not pinned
 }
 
-func (r *runnerWithPenalty) RunBenchmark(ctx context.Context, bench data.Function, run int, suiteExec int, test string) (int, error) {
+func (r *runnerWithPenalty) RunBenchmark(ctx context.Context, bench data.Function, run int, suiteExec int, v Variant) (int, error) {
+	if r.journal == nil {
+		return r.runBenchmark(ctx, bench, run, suiteExec, v)
+	}
+
+	name := relBenchName(bench)
+	if r.journal.Done(run, suiteExec, v.Test, name) {
+		fmt.Printf("### Skip Benchmark completed before: %s\n", name)
+		return 0, nil
+	}
+
+	executed, err := r.runBenchmark(ctx, bench, run, suiteExec, v)
+	// failed executions (e.g., below the strikes of the penalty policy) are repeated when resuming;
+	// their results are removed when resuming, penalised benchmarks are not repeated
+	if err != nil || (executed == 0 && !r.penalisedBenchs.has(name, run)) {
+		return executed, err
+	}
+	return executed, r.journal.Complete(run, suiteExec, v.Test, name)
+}
+
+func (r *runnerWithPenalty) runBenchmark(ctx context.Context, bench data.Function, run int, suiteExec int, v Variant) (int, error) {
+	if r.adaptive.CIWidth > 0 {
+		return r.runAdaptive(ctx, bench, run, suiteExec, v)
+	}
+
 	if r.benchDuration != 0 {
 		startBench := time.Now()
 		benchCount := 0
 		for time.Since(startBench).Seconds() < r.benchDuration.Seconds() {
-			exec, err := r.RunBenchmarkOnce(ctx, bench, run, suiteExec, benchCount, test)
+			exec, err := r.RunBenchmarkOnce(ctx, bench, run, suiteExec, benchCount, v)
 			if err != nil || !exec {
 				return benchCount, err
 			}
@@ -123,7 +254,7 @@ func (r *runnerWithPenalty) RunBenchmark(ctx context.Context, bench data.Functio
 	}
 
 	// no benchmark duration supplied -> only one benchmark execution
-	exec, err := r.RunBenchmarkOnce(ctx, bench, run, suiteExec, 0, test)
+	exec, err := r.RunBenchmarkOnce(ctx, bench, run, suiteExec, 0, v)
 	if exec {
 		return 1, err
 	}
@@ -131,143 +262,285 @@ func (r *runnerWithPenalty) RunBenchmark(ctx context.Context, bench data.Functio
 
 }
 
-func (r *runnerWithPenalty) RunBenchmarkOnce(ctx context.Context, bench data.Function, run int, suiteExec int, benchExec int, test string) (bool, error) {
-	relBenchName := fmt.Sprintf("%s/%s::%s", bench.Pkg, bench.File, bench.Name)
+func (r *runnerWithPenalty) RunBenchmarkOnce(ctx context.Context, bench data.Function, run int, suiteExec int, benchExec int, v Variant) (bool, error) {
+	_, exec, err := r.runBenchmarkOnce(ctx, bench, run, suiteExec, benchExec, v)
+	return exec, err
+}
+
+// runBenchmarkOnce executes the benchmark once and returns its records (if executed)
+func (r *runnerWithPenalty) runBenchmarkOnce(ctx context.Context, bench data.Function, run int, suiteExec int, benchExec int, v Variant) ([]Record, bool, error) {
+	relBenchName := relBenchName(bench)
 	// check if benchmark is penaltised
-	_, penaltised := r.penalisedBenchs[relBenchName]
-	if penaltised {
+	if r.penalisedBenchs.has(relBenchName, run) {
 		fmt.Printf("### Do not execute Benchmark due to penalty: %s\n", relBenchName)
-		return false, nil
+		return nil, false, nil
 	}
 
 	fmt.Printf("### Execute Benchmark: %s\n", bench.Name)
-	args := append(r.cmdArgs, fmt.Sprintf(cmdArgsBench, bench.Name))
+	if r.orderLog != nil {
+		err := r.orderLog.write(run, suiteExec, benchExec, v.Test, relBenchName)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	procs := benchProcs(r.env, r.cpuSet)
+	args := make([]string, 0, len(r.cmdArgs)+5)
+	args = append(args, r.cmdArgs...)
+	args = append(args, fmt.Sprintf(cmdArgsCPU, procs), benchArg(bench.Name))
 	// add profile if necessary
 	if r.profile != data.NoProfile {
-		args = r.profileCmdArgs(args, bench, run, suiteExec, benchExec, test)
+		args = r.profileCmdArgs(args, bench, run, suiteExec, benchExec, v.Test)
 	}
 
-	// intentionally not using exec.CommandContex -> let's the benchmark finish first
-	c := exec.Command(executil.GoCommand(r.env), args...)
+	// the whole process group (go command, compiler, and test binary) is killed after the hard deadline
+	var c *exec.Cmd
+	if r.binaries != nil {
+		bin, out, err := r.binary(ctx, bench.Pkg, v)
+		if err != nil {
+			if !isSupervisedFailure(err) {
+				return nil, false, fmt.Errorf("Could not compile '%s': %v", bench.Pkg, err)
+			}
+			reason := classify(string(out), err, nil)
+			switch reason {
+			case CanceledFailure:
+				return nil, false, err
+			case NoFailure:
+				reason = BuildFailure
+			}
+			fmt.Printf("%s failed (%s):\n%s\n", relBenchName, reason, out)
+			return nil, false, r.fail(bench, run, suiteExec, benchExec, v, reason, string(out))
+		}
+		c = r.command(bin, testBinaryArgs(args))
+	} else {
+		goArgs := make([]string, 0, len(args)+len(v.BuildArgs)+1)
+		goArgs = append(goArgs, cmdArgsTest)
+		goArgs = append(goArgs, args...)
+		goArgs = append(goArgs, v.BuildArgs...)
+		c = r.goCommand(goArgs)
+	}
+	c.Dir = filepath.Join(r.projectRoot, bench.Pkg)
 	c.Env = r.env
 
-	res, err := c.CombinedOutput()
+	res, execErr := Supervise(ctx, c, r.deadline, r.killGrace)
+	if execErr != nil && !isSupervisedFailure(execErr) {
+		// e.g., the go command could not be started
+		return nil, false, fmt.Errorf("Could not execute '%s': %v", c.Args, execErr)
+	}
 	resStr := string(res)
-	if err != nil {
-		fmt.Printf("Error while executing command '%s\n", c.Args)
-		if strings.Contains(resStr, benchTimeoutMsg) {
+	result, parseErr := parseOutput(resStr, procs)
+
+	switch reason := classify(resStr, execErr, parseErr); reason {
+	case NoFailure:
+		if execErr != nil {
+			fmt.Printf("Error while executing command '%s\n%s\n", c.Args, resStr)
+		}
+	case CanceledFailure:
+		fmt.Printf("%s canceled\n", relBenchName)
+		return nil, false, execErr
+	default:
+		if reason == TimeoutFailure {
 			fmt.Printf("%s timed out after %s\n", relBenchName, r.timeout)
-			r.penalisedBenchs[relBenchName] = struct{}{}
-			err = nil
-			return false, nil
+		} else {
+			fmt.Printf("%s failed (%s):\n%s\n", relBenchName, reason, resStr)
 		}
-		fmt.Printf("%s\n", resStr)
+		return nil, false, r.fail(bench, run, suiteExec, benchExec, v, reason, resStr)
 	}
 
-	result, err := r.resultParser.parse(resStr)
+	recs := r.records(v.Test, run, suiteExec, benchExec, bench, result)
+	r.outLock.Lock()
+	err := saveBenchOut(recs, r.warmup.Drop, r.out)
+	r.outLock.Unlock()
 	if err != nil {
-		if _, ok := err.(resultNotParsable); ok {
-			fmt.Printf("%s result could not be parsed\n", relBenchName)
-			r.penalisedBenchs[relBenchName] = struct{}{}
-			return false, nil
+		fmt.Printf("Could not save results of %s\n", relBenchName)
+		return nil, false, err
+	}
+
+	return recs, true, nil
+}
+
+// fail records the failed benchmark execution in the journal, output, and failure log and penalises the benchmark according to the penalty policy
+func (r *runnerWithPenalty) fail(bench data.Function, run int, suiteExec int, benchExec int, v Variant, reason FailureReason, out string) error {
+	name := relBenchName(bench)
+	penalised := r.penalisedBenchs.fail(name, run)
+	if penalised {
+		fmt.Printf("Penalise %s (%s)\n", name, reason)
+	}
+
+	if r.journal != nil {
+		err := r.journal.Fail(run, suiteExec, v.Test, name)
+		if err != nil {
+			return err
 		}
-		return false, err
+		if penalised {
+			err = r.journal.Penalise(run, suiteExec, v.Test, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(out) > maxFailureMessage {
+		out = out[len(out)-maxFailureMessage:]
+	}
+	f := Failure{
+		Run:       run,
+		SuiteExec: suiteExec,
+		BenchExec: benchExec,
+		Test:      v.Test,
+		Benchmark: filepath.Join(bench.Pkg, bench.File, bench.Name),
+		Reason:    reason,
+		Message:   out,
+		Penalised: penalised,
 	}
 
-	saveBenchOut(test, run, suiteExec, benchExec, bench, result, r.out, r.benchMem)
+	if r.failures != nil {
+		err := r.failures.WriteFailure(f)
+		if err != nil {
+			return err
+		}
+	}
 
-	return true, nil
+	fs, ok := r.out.(FailureSink)
+	if !ok {
+		return nil
+	}
+	r.outLock.Lock()
+	defer r.outLock.Unlock()
+	return fs.WriteFailure(f)
+}
+
+func relBenchName(bench data.Function) string {
+	return fmt.Sprintf("%s/%s::%s", bench.Pkg, bench.File, bench.Name)
 }
 
-func (r *runnerWithPenalty) RunUntil(ctx context.Context, run int, test string, done <-chan struct{}) (int, error) {
+func (r *runnerWithPenalty) goCommand(args []string) *exec.Cmd {
+	return r.command(executil.GoCommand(r.env), args)
+}
+
+// benchProcs returns the GOMAXPROCS of benchmarks executed with env and pinned to cpuSet (if not empty):
+// GOMAXPROCS of env if set, otherwise the number of CPUs available.
+// It is passed explicitly (go test -cpu) to tell the suffix of result names apart from sub-benchmark names.
+func benchProcs(env []string, cpuSet string) int {
+	procs, err := strconv.Atoi(executil.LookupEnv(env, "GOMAXPROCS"))
+	if err == nil && procs > 0 {
+		return procs
+	}
+	if cpuSet != "" {
+		cpus, err := parseCPUList(cpuSet)
+		if err == nil && len(cpus) > 0 {
+			return len(cpus)
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// command returns the command pinned to the CPU set of the runner (if any)
+func (r *runnerWithPenalty) command(name string, args []string) *exec.Cmd {
+	if r.cpuSet == "" {
+		return exec.Command(name, args...)
+	}
+	pinnedArgs := make([]string, 0, len(args)+3)
+	pinnedArgs = append(pinnedArgs, cmdArgsCPUList, r.cpuSet, name)
+	pinnedArgs = append(pinnedArgs, args...)
+	return exec.Command(cmdTaskset, pinnedArgs...)
+}
+
+func (r *runnerWithPenalty) RunUntil(ctx context.Context, run int, v Variant, done <-chan struct{}) (int, error) {
 	benchCount := 0
 Forever:
 	for suiteExec := 0; true; suiteExec++ {
-		for pkgName, pkg := range r.benchs {
-			fmt.Printf("# Execute Benchmarks in Dir: %s\n", pkgName)
-
-			dir := filepath.Join(r.projectRoot, pkgName)
+		var prev *data.Function
+		for _, bench := range r.suite(run, suiteExec, v.Test) {
+			printLocation(prev, bench)
+			prev = &bench
 
-			err := os.Chdir(dir)
+			executed, err := r.RunBenchmark(ctx, bench, run, suiteExec, v)
 			if err != nil {
 				return benchCount, err
 			}
+			benchCount += executed
 
-			for fileName, file := range pkg {
-				fmt.Printf("## Execute Benchmarks of File: %s\n", fileName)
-				for _, bench := range file {
-					executed, err := r.RunBenchmark(ctx, bench, run, suiteExec, test)
-
-					if err != nil {
-						return benchCount, err
-					}
-					benchCount += executed
-
-					select {
-					case <-ctx.Done():
-						return benchCount, ctx.Err()
-					case <-done:
-						break Forever
-					default:
-					}
-				}
+			select {
+			case <-ctx.Done():
+				return benchCount, ctx.Err()
+			case <-done:
+				break Forever
+			default:
 			}
 		}
 	}
 	return benchCount, nil
 }
 
-func (r *runnerWithPenalty) RunOnce(ctx context.Context, run int, test string) (int, error) {
+func (r *runnerWithPenalty) RunOnce(ctx context.Context, run int, v Variant) (int, error) {
 	benchCount := 0
-	for pkgName, pkg := range r.benchs {
-		fmt.Printf("# Execute Benchmarks in Dir: %s\n", pkgName)
+	var prev *data.Function
+	for _, bench := range r.suite(run, 0, v.Test) {
+		printLocation(prev, bench)
+		prev = &bench
 
-		dir := filepath.Join(r.projectRoot, pkgName)
-
-		err := os.Chdir(dir)
+		executed, err := r.RunBenchmark(ctx, bench, run, 0, v)
 		if err != nil {
 			return benchCount, err
 		}
+		benchCount += executed
 
-		for fileName, file := range pkg {
-			fmt.Printf("## Execute Benchmarks of File: %s\n", fileName)
-			for _, bench := range file {
-				executed, err := r.RunBenchmark(ctx, bench, run, 0, test)
-				if err != nil {
-					return benchCount, err
-				}
-				benchCount += executed
-
-				select {
-				case <-ctx.Done():
-					return benchCount, ctx.Err()
-				default:
-				}
-			}
+		select {
+		case <-ctx.Done():
+			return benchCount, ctx.Err()
+		default:
 		}
 	}
 	return benchCount, nil
 }
 
-func (r *runnerWithPenalty) Run(ctx context.Context, run int, test string) (int, error) {
+func (r *runnerWithPenalty) Run(ctx context.Context, run int, v Variant) (int, error) {
+	r.retainBinaries(v)
 	if r.runDuration != 0 {
-		done := make(chan struct{})
-		go func() {
-		Loop:
-			for {
-				select {
-				case <-time.After(r.runDuration):
-					break Loop
-				case <-ctx.Done():
-					break Loop
-				default:
-				}
+		return r.RunUntil(ctx, run, v, runDeadline(ctx, r.runDuration))
+	}
+	return r.RunOnce(ctx, run, v)
+}
+
+// RunInterleaved executes every benchmark for all variants (in random order) before the next benchmark.
+func (r *runnerWithPenalty) RunInterleaved(ctx context.Context, run int, vs []Variant) (int, error) {
+	r.retainBinaries(vs...)
+	benchCount := 0
+	var prev *data.Function
+	for _, bench := range r.suite(run, 0, interleavedTest) {
+		printLocation(prev, bench)
+		prev = &bench
+
+		for _, v := range r.interleaved(run, bench, vs) {
+			fmt.Printf("### Variant: %s\n", v.Test)
+			executed, err := r.RunBenchmark(ctx, bench, run, 0, v)
+			if err != nil {
+				return benchCount, err
 			}
-			close(done)
-		}()
-		return r.RunUntil(ctx, run, test, done)
+			benchCount += executed
+
+			select {
+			case <-ctx.Done():
+				return benchCount, ctx.Err()
+			default:
+			}
+		}
 	}
-	return r.RunOnce(ctx, run, test)
+	return benchCount, nil
+}
+
+// runDeadline returns a channel that is closed after d or when ctx is done
+func runDeadline(ctx context.Context, d time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+		close(done)
+	}()
+	return done
 }
 
 func (r *runnerWithPenalty) profileCmdArgs(args []string, bench data.Function, run int, suiteExec int, benchExec int, test string) []string {
@@ -287,15 +560,15 @@ func (r *runnerWithPenalty) profileCmdArgs(args []string, bench data.Function, r
 	return args
 }
 
-func TimedRun(ctx context.Context, r Runner, run int, test string) (int, error, time.Duration) {
+func TimedRun(ctx context.Context, r Runner, run int, v Variant) (int, error, time.Duration) {
 	now := time.Now()
-	execBenchs, err := r.Run(ctx, run, test)
+	execBenchs, err := r.Run(ctx, run, v)
 	dur := time.Since(now)
 	return execBenchs, err, dur
 }
 
 func profileName(bench data.Function, run int, suiteExec int, benchExec int, test string, t string) string {
-	return fmt.Sprintf("%d-%d-%d_%s_%s_%s_%s", run, suiteExec, benchExec, replaceSlashes(test), replaceSlashes(bench.Pkg), bench.Name, t)
+	return fmt.Sprintf("%d-%d-%d_%s_%s_%s_%s", run, suiteExec, benchExec, replaceSlashes(test), replaceSlashes(bench.Pkg), replaceSlashes(bench.Name), t)
 }
 
 func replaceSlashes(p string) string {
@@ -313,26 +586,15 @@ func replaceSlashes(p string) string {
 	return strings.Replace(p, "/", "-", -1)
 }
 
-func saveBenchOut(test string, run int, suiteExec int, benchExec int, b data.Function, res []result, out csv.Writer, benchMem bool) {
-	outSize := 5
-	if benchMem {
-		outSize += 2
-	}
-
-	for _, result := range res {
-		rec := make([]string, 0, outSize)
-		rec = append(rec, fmt.Sprintf("%d-%d-%d", run, suiteExec, benchExec))
-		rec = append(rec, test)
-		rec = append(rec, filepath.Join(b.Pkg, b.File, b.Name))
-		rec = append(rec, strconv.Itoa(result.Invocations))
-		rec = append(rec, strconv.FormatFloat(float64(result.Runtime), 'f', -1, 32))
-
-		if benchMem {
-			rec = append(rec, strconv.FormatInt(int64(result.Memory), 10))
-			rec = append(rec, strconv.FormatInt(int64(result.Allocations), 10))
+func saveBenchOut(recs []Record, dropWarmup bool, out ResultSink) error {
+	for _, rec := range recs {
+		if dropWarmup && rec.Warmup {
+			continue
+		}
+		err := out.Write(rec)
+		if err != nil {
+			return err
 		}
-
-		out.Write(rec)
-		out.Flush()
 	}
+	return nil
 }
@@ -0,0 +1,438 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	cmdSQLite         = "sqlite3"
+	cmdArgsSQLiteBail = "-bail"
+	cmdArgsSQLiteCSV  = "-csv"
+)
+
+// sqliteSchema is the schema of version sqliteVersion, which is stored as user_version of the database
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS results (
+	experiment TEXT NOT NULL,
+	run INTEGER NOT NULL,
+	suite_exec INTEGER NOT NULL,
+	bench_exec INTEGER NOT NULL,
+	test TEXT NOT NULL,
+	benchmark TEXT NOT NULL,
+	invocations INTEGER NOT NULL,
+	metric TEXT NOT NULL,
+	value REAL NOT NULL,
+	iteration INTEGER NOT NULL,
+	warmup INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS failures (
+	experiment TEXT NOT NULL,
+	run INTEGER NOT NULL,
+	suite_exec INTEGER NOT NULL,
+	bench_exec INTEGER NOT NULL,
+	test TEXT NOT NULL,
+	benchmark TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	message TEXT NOT NULL
+);
+`
+
+// sqliteMigrations migrate a database of schema version i+1 to version i+2.
+// Databases of version 1 have no user_version (i.e., 0) but a results table.
+var sqliteMigrations = []string{
+	// iteration and warm-up tags of the results
+	`ALTER TABLE results ADD COLUMN iteration INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE results ADD COLUMN warmup INTEGER NOT NULL DEFAULT 0;
+`,
+}
+
+var sqliteVersion = len(sqliteMigrations) + 1
+
+// sqliteSyncQuery is answered by sqlite3 once all previous statements are executed
+const sqliteSyncQuery = "SELECT 1;"
+
+// sqliteIncomplete removes the results and failures of an experiment (first argument)
+// that do not belong to a completed benchmark execution (temporary table completed).
+const sqliteIncomplete = `DELETE FROM %[1]s WHERE experiment = %[2]s AND NOT EXISTS (SELECT 1 FROM completed c WHERE
+	c.run = %[1]s.run AND c.suite_exec = %[1]s.suite_exec AND c.test = %[1]s.test AND
+	(ltrim(%[1]s.benchmark, '/') = c.benchmark OR substr(ltrim(%[1]s.benchmark, '/'), 1, length(c.benchmark) + 1) = c.benchmark || '/'));
+`
+
+const sqliteVersionQuery = "PRAGMA user_version; SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'results';"
+
+// ResultSink stores benchmark results.
+// Implementations do not need to be safe for concurrent use.
+type ResultSink interface {
+	Write(r Record) error
+	Close() error
+}
+
+// NewCSVSink creates a sink that writes one semicolon-separated line per record of the form
+// run-suiteExec-benchExec-iteration;test;benchmark;invocations;ns/op[;B/op;allocs/op].
+// Iterations of warm-up records are prefixed with w (e.g., 0-0-0-w1).
+// Failed benchmark executions are written as run-suiteExec-benchExec;test;benchmark;failed;reason.
+func NewCSVSink(w io.Writer, benchMem bool) ResultSink {
+	out := csv.NewWriter(w)
+	out.Comma = ';'
+	return &csvSink{
+		out:      out,
+		benchMem: benchMem,
+	}
+}
+
+type csvSink struct {
+	out      *csv.Writer
+	benchMem bool
+}
+
+func (s *csvSink) Write(r Record) error {
+	outSize := csvColsRuntime
+	if s.benchMem {
+		outSize = csvColsMem
+	}
+
+	rec := make([]string, 0, outSize)
+	iteration := strconv.Itoa(r.Iteration)
+	if r.Warmup {
+		iteration = csvWarmupPrefix + iteration
+	}
+	rec = append(rec, fmt.Sprintf("%d-%d-%d-%s", r.Run, r.SuiteExec, r.BenchExec, iteration))
+	rec = append(rec, r.Test)
+	rec = append(rec, r.Benchmark)
+	rec = append(rec, strconv.Itoa(r.Invocations))
+	rec = append(rec, strconv.FormatFloat(r.Metrics[timeUnit], 'f', -1, 32))
+
+	if s.benchMem {
+		rec = append(rec, strconv.FormatInt(int64(r.Metrics[bytesUnit]), 10))
+		rec = append(rec, strconv.FormatInt(int64(r.Metrics[allocsUnit]), 10))
+	}
+
+	err := s.out.Write(rec)
+	if err != nil {
+		return err
+	}
+	s.out.Flush()
+	return s.out.Error()
+}
+
+func (s *csvSink) WriteFailure(f Failure) error {
+	outSize := csvColsRuntime
+	if s.benchMem {
+		outSize = csvColsMem
+	}
+
+	rec := make([]string, outSize)
+	rec[0] = fmt.Sprintf("%d-%d-%d", f.Run, f.SuiteExec, f.BenchExec)
+	rec[1] = f.Test
+	rec[2] = f.Benchmark
+	rec[3] = csvFailed
+	rec[4] = string(f.Reason)
+
+	err := s.out.Write(rec)
+	if err != nil {
+		return err
+	}
+	s.out.Flush()
+	return s.out.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.out.Flush()
+	return s.out.Error()
+}
+
+// jsonMeasurement is a single metric value of a record
+type jsonMeasurement struct {
+	Run         int     `json:"run"`
+	SuiteExec   int     `json:"suiteExec"`
+	BenchExec   int     `json:"benchExec"`
+	Test        string  `json:"test"`
+	Benchmark   string  `json:"benchmark"`
+	Invocations int     `json:"invocations"`
+	Metric      string  `json:"metric"`
+	Value       float64 `json:"value"`
+	Iteration   int     `json:"iteration"`
+	Warmup      bool    `json:"warmup"`
+}
+
+// jsonFailure is a failed benchmark execution
+type jsonFailure struct {
+	Run       int    `json:"run"`
+	SuiteExec int    `json:"suiteExec"`
+	BenchExec int    `json:"benchExec"`
+	Test      string `json:"test"`
+	Benchmark string `json:"benchmark"`
+	Failure   string `json:"failure"`
+	Message   string `json:"message"`
+	Penalised bool   `json:"penalised"`
+}
+
+func newJSONFailure(f Failure) jsonFailure {
+	return jsonFailure{
+		Run:       f.Run,
+		SuiteExec: f.SuiteExec,
+		BenchExec: f.BenchExec,
+		Test:      f.Test,
+		Benchmark: f.Benchmark,
+		Failure:   string(f.Reason),
+		Message:   f.Message,
+		Penalised: f.Penalised,
+	}
+}
+
+// NewJSONLSink creates a sink that writes one JSON object per measured metric and line (JSON Lines).
+func NewJSONLSink(w io.Writer) ResultSink {
+	bw := bufio.NewWriter(w)
+	return &jsonlSink{
+		w: bw,
+		e: json.NewEncoder(bw),
+	}
+}
+
+type jsonlSink struct {
+	w *bufio.Writer
+	e *json.Encoder
+}
+
+func (s *jsonlSink) Write(r Record) error {
+	for _, unit := range metricUnits(r) {
+		err := s.e.Encode(jsonMeasurement{
+			Run:         r.Run,
+			SuiteExec:   r.SuiteExec,
+			BenchExec:   r.BenchExec,
+			Test:        r.Test,
+			Benchmark:   r.Benchmark,
+			Invocations: r.Invocations,
+			Metric:      unit,
+			Value:       r.Metrics[unit],
+			Iteration:   r.Iteration,
+			Warmup:      r.Warmup,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *jsonlSink) WriteFailure(f Failure) error {
+	err := s.e.Encode(newJSONFailure(f))
+	if err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *jsonlSink) Close() error {
+	return s.w.Flush()
+}
+
+// NewSQLiteSink creates a sink that inserts one row per measured metric into the results table of a SQLite database.
+// Rows are tagged with experiment, which allows for storing multiple experiments in the same database.
+// Databases of earlier versions are migrated to the current schema.
+// If resume is not nil, results and failures of experiment that do not belong to a benchmark execution completed according to resume are removed.
+// Failed statements are reported by the write that executes them.
+// It requires the sqlite3 command line shell.
+func NewSQLiteSink(path, experiment string, resume *Journal) (ResultSink, error) {
+	setup, err := sqliteSchemaUpdate(path)
+	if err != nil {
+		return nil, err
+	}
+	if resume != nil {
+		setup += sqliteDropIncomplete(experiment, resume)
+	}
+	res, err := exec.Command(cmdSQLite, cmdArgsSQLiteBail, path, setup).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("Could not set up %s: %v\n%s", path, err, res)
+	}
+
+	c := exec.Command(cmdSQLite, cmdArgsSQLiteBail, path)
+	in, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+
+	err = c.Start()
+	if err != nil {
+		return nil, fmt.Errorf("Could not start %s: %v", cmdSQLite, err)
+	}
+
+	// sqlite3 exits on the first error (-bail), which closes lines
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	return &sqliteSink{
+		c:          c,
+		in:         in,
+		lines:      lines,
+		stderr:     &stderr,
+		experiment: experiment,
+	}, nil
+}
+
+// sqliteDropIncomplete returns the statements that remove the results of experiment that do not belong to an execution completed according to j
+func sqliteDropIncomplete(experiment string, j *Journal) string {
+	var stmts strings.Builder
+	stmts.WriteString("BEGIN;\nCREATE TEMP TABLE completed (run INTEGER, suite_exec INTEGER, test TEXT, benchmark TEXT);\n")
+	j.l.Lock()
+	for k := range j.completed {
+		fmt.Fprintf(&stmts, "INSERT INTO completed VALUES (%d, %d, %s, %s);\n", k.run, k.suiteExec, sqlString(k.test), sqlString(k.bench))
+	}
+	j.l.Unlock()
+	fmt.Fprintf(&stmts, sqliteIncomplete, "results", sqlString(experiment))
+	fmt.Fprintf(&stmts, sqliteIncomplete, "failures", sqlString(experiment))
+	stmts.WriteString("DROP TABLE completed;\nCOMMIT;\n")
+	return stmts.String()
+}
+
+// sqliteSchemaUpdate returns the statements that create or migrate the schema of the database path
+func sqliteSchemaUpdate(path string) (string, error) {
+	res, err := exec.Command(cmdSQLite, cmdArgsSQLiteBail, path, sqliteVersionQuery).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("Could not read schema version of %s: %v\n%s", path, err, res)
+	}
+	lines := strings.Fields(string(res))
+	if len(lines) != 2 {
+		return "", fmt.Errorf("Unexpected schema version of %s: %s", path, res)
+	}
+	version, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return "", fmt.Errorf("Invalid schema version of %s: %s", path, lines[0])
+	}
+	if version == 0 && lines[1] != "0" {
+		version = 1
+	}
+
+	if version > sqliteVersion {
+		return "", fmt.Errorf("Schema version %d of %s is newer than the supported version %d", version, path, sqliteVersion)
+	}
+
+	var stmts strings.Builder
+	if version > 0 {
+		for _, m := range sqliteMigrations[version-1:] {
+			stmts.WriteString(m)
+		}
+	}
+	// creates the tables missing in earlier versions
+	stmts.WriteString(sqliteSchema)
+	return fmt.Sprintf("BEGIN;\n%sPRAGMA user_version = %d;\nCOMMIT;\n", stmts.String(), sqliteVersion), nil
+}
+
+type sqliteSink struct {
+	c          *exec.Cmd
+	in         io.WriteCloser
+	lines      <-chan string // lines of the standard output
+	stderr     *bytes.Buffer
+	exitErr    error // error of sqlite3 if it exited before Close
+	experiment string
+}
+
+func (s *sqliteSink) Write(r Record) error {
+	var stmts strings.Builder
+	stmts.WriteString("BEGIN;\n")
+	for _, unit := range metricUnits(r) {
+		fmt.Fprintf(&stmts, "INSERT INTO results (experiment, run, suite_exec, bench_exec, test, benchmark, invocations, metric, value, iteration, warmup) VALUES (%s, %d, %d, %d, %s, %s, %d, %s, %s, %d, %t);\n",
+			sqlString(s.experiment),
+			r.Run,
+			r.SuiteExec,
+			r.BenchExec,
+			sqlString(r.Test),
+			sqlString(r.Benchmark),
+			r.Invocations,
+			sqlString(unit),
+			strconv.FormatFloat(r.Metrics[unit], 'g', -1, 64),
+			r.Iteration,
+			r.Warmup,
+		)
+	}
+	stmts.WriteString("COMMIT;\n")
+	return s.exec(stmts.String())
+}
+
+func (s *sqliteSink) WriteFailure(f Failure) error {
+	stmt := fmt.Sprintf("INSERT INTO failures (experiment, run, suite_exec, bench_exec, test, benchmark, reason, message) VALUES (%s, %d, %d, %d, %s, %s, %s, %s);\n",
+		sqlString(s.experiment),
+		f.Run,
+		f.SuiteExec,
+		f.BenchExec,
+		sqlString(f.Test),
+		sqlString(f.Benchmark),
+		sqlString(string(f.Reason)),
+		sqlString(f.Message),
+	)
+	return s.exec(stmt)
+}
+
+// exec executes stmts and waits until sqlite3 executed them, i.e., failed statements are reported immediately
+func (s *sqliteSink) exec(stmts string) error {
+	if s.exitErr != nil {
+		return s.exitErr
+	}
+
+	_, err := io.WriteString(s.in, stmts+sqliteSyncQuery+"\n")
+	if err != nil {
+		return s.exited(fmt.Errorf("Could not write to %s: %v", cmdSQLite, err))
+	}
+	if _, ok := <-s.lines; !ok {
+		return s.exited(nil)
+	}
+	return nil
+}
+
+// exited returns the error of sqlite3 that exited unexpectedly, or err if sqlite3 exited successfully
+func (s *sqliteSink) exited(err error) error {
+	s.in.Close()
+	werr := s.c.Wait()
+	if werr != nil {
+		err = fmt.Errorf("%s failed: %v\n%s", cmdSQLite, werr, s.stderr.String())
+	} else if err == nil {
+		err = fmt.Errorf("%s exited unexpectedly", cmdSQLite)
+	}
+	s.exitErr = err
+	return err
+}
+
+func (s *sqliteSink) Close() error {
+	if s.exitErr != nil {
+		return s.exitErr
+	}
+	s.in.Close()
+	err := s.c.Wait()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", cmdSQLite, err, s.stderr.String())
+	}
+	return nil
+}
+
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func metricUnits(r Record) []string {
+	units := make([]string, 0, len(r.Metrics))
+	for unit := range r.Metrics {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
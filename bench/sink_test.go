@@ -0,0 +1,378 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRecord() Record {
+	return Record{
+		Run:         1,
+		SuiteExec:   2,
+		BenchExec:   3,
+		Test:        Baseline,
+		Benchmark:   "pkg/a_test.go/BenchmarkA",
+		Invocations: 1000,
+		Iteration:   4,
+		Metrics: map[string]float64{
+			timeUnit:   58.6,
+			bytesUnit:  16,
+			allocsUnit: 1,
+		},
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewCSVSink(&buf, true)
+	err := s.Write(testRecord())
+	if err != nil {
+		t.Fatalf("Could not write record: %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Could not close sink: %v", err)
+	}
+
+	expected := "1-2-3-4;Baseline;pkg/a_test.go/BenchmarkA;1000;58.6;16;1\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected output\n-- expected --\n%s\n-- was --\n%s\n", expected, buf.String())
+	}
+
+	rs, err := ReadCSV(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Could not read output: %v", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("Unexpected number of records: expected 1, was %d", len(rs))
+	}
+	r := rs[0]
+	exp := testRecord()
+	if r.Run != exp.Run || r.SuiteExec != exp.SuiteExec || r.BenchExec != exp.BenchExec ||
+		r.Test != exp.Test || r.Benchmark != exp.Benchmark || r.Invocations != exp.Invocations ||
+		r.Iteration != exp.Iteration || r.Warmup != exp.Warmup {
+		t.Errorf("Unexpected record: expected %+v, was %+v", exp, r)
+	}
+	for unit, v := range exp.Metrics {
+		if r.Metrics[unit] != v {
+			t.Errorf("Unexpected %s: expected %f, was %f", unit, v, r.Metrics[unit])
+		}
+	}
+}
+
+func TestReadCSVWarmup(t *testing.T) {
+	in := "1-2-3-w0;Baseline;pkg/a_test.go/BenchmarkA;1000;58.6;16;1\n" +
+		"1-2-3;Baseline;pkg/a_test.go/BenchmarkA;1000;58.6;16;1\n"
+	rs, err := ReadCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Could not read input: %v", err)
+	}
+	if len(rs) != 2 {
+		t.Fatalf("Unexpected number of records: expected 2, was %d", len(rs))
+	}
+	if !rs[0].Warmup || rs[0].Iteration != 0 {
+		t.Errorf("Expected warm-up iteration 0, was %+v", rs[0])
+	}
+	if rs[1].Warmup || rs[1].BenchExec != 3 {
+		t.Errorf("Unexpected record without iteration: %+v", rs[1])
+	}
+
+	var buf bytes.Buffer
+	s := NewCSVSink(&buf, true)
+	err = s.Write(rs[0])
+	if err != nil {
+		t.Fatalf("Could not write record: %v", err)
+	}
+	s.Close()
+	if !strings.HasPrefix(buf.String(), "1-2-3-w0;") {
+		t.Errorf("Unexpected warm-up id: %s", buf.String())
+	}
+}
+
+func TestCSVSinkFailure(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewCSVSink(&buf, true)
+	err := s.Write(testRecord())
+	if err != nil {
+		t.Fatalf("Could not write record: %v", err)
+	}
+	err = s.(FailureSink).WriteFailure(Failure{Run: 1, SuiteExec: 2, BenchExec: 3, Test: Baseline, Benchmark: "pkg/a_test.go/BenchmarkB", Reason: PanicFailure})
+	if err != nil {
+		t.Fatalf("Could not write failure: %v", err)
+	}
+	s.Close()
+
+	if !strings.HasSuffix(buf.String(), "1-2-3;Baseline;pkg/a_test.go/BenchmarkB;failed;panic;;\n") {
+		t.Errorf("Unexpected failure line in\n%s", buf.String())
+	}
+
+	rs, err := ReadCSV(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Could not read output: %v", err)
+	}
+	if len(rs) != 1 {
+		t.Errorf("Expected failures to be skipped, read %d records", len(rs))
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONLSink(&buf)
+	err := s.Write(testRecord())
+	if err != nil {
+		t.Fatalf("Could not write record: %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Could not close sink: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected number of lines: expected 3, was %d", len(lines))
+	}
+	for _, l := range lines {
+		var m map[string]interface{}
+		err := json.Unmarshal([]byte(l), &m)
+		if err != nil {
+			t.Fatalf("Could not parse line '%s': %v", l, err)
+		}
+		for _, field := range []string{"run", "suiteExec", "benchExec", "test", "benchmark", "invocations", "iteration", "warmup", "metric", "value"} {
+			if _, ok := m[field]; !ok {
+				t.Errorf("Field %s missing in '%s'", field, l)
+			}
+		}
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONLSink(&buf)
+	w := testRecord()
+	w.Iteration = 0
+	w.Warmup = true
+	for _, r := range []Record{w, testRecord()} {
+		err := s.Write(r)
+		if err != nil {
+			t.Fatalf("Could not write record: %v", err)
+		}
+	}
+	err := s.(FailureSink).WriteFailure(Failure{Test: Baseline, Benchmark: "pkg/a_test.go/BenchmarkB", Reason: PanicFailure})
+	if err != nil {
+		t.Fatalf("Could not write failure: %v", err)
+	}
+
+	rs, err := ReadJSONL(&buf)
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	if exp := []Record{w, testRecord()}; !reflect.DeepEqual(rs, exp) {
+		t.Errorf("Unexpected records\nexpected: %v\nwas:      %v", exp, rs)
+	}
+}
+
+const sqliteSchemaV1 = `CREATE TABLE results (
+	experiment TEXT NOT NULL,
+	run INTEGER NOT NULL,
+	suite_exec INTEGER NOT NULL,
+	bench_exec INTEGER NOT NULL,
+	test TEXT NOT NULL,
+	benchmark TEXT NOT NULL,
+	invocations INTEGER NOT NULL,
+	metric TEXT NOT NULL,
+	value REAL NOT NULL
+);
+INSERT INTO results VALUES ('old', 0, 0, 0, 'baseline', 'pkg/a_test.go/BenchmarkA', 1000, 'ns/op', 60);
+`
+
+func TestSQLiteSinkMigration(t *testing.T) {
+	if _, err := exec.LookPath(cmdSQLite); err != nil {
+		t.Skipf("%s not installed", cmdSQLite)
+	}
+	dir, err := ioutil.TempDir("", "goabs-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "v1.db")
+	out, err := exec.Command(cmdSQLite, path, sqliteSchemaV1).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Could not create v1 database: %v\n%s", err, out)
+	}
+
+	// the second time, the database is already migrated
+	for i := 0; i < 2; i++ {
+		s, err := NewSQLiteSink(path, "new", nil)
+		if err != nil {
+			t.Fatalf("Could not open v1 database: %v", err)
+		}
+		err = s.Write(testRecord())
+		if err != nil {
+			t.Fatalf("Could not write record: %v", err)
+		}
+		err = s.(FailureSink).WriteFailure(Failure{Test: Baseline, Benchmark: "pkg/a_test.go/BenchmarkB", Reason: PanicFailure})
+		if err != nil {
+			t.Fatalf("Could not write failure: %v", err)
+		}
+		err = s.Close()
+		if err != nil {
+			t.Fatalf("Could not close sink: %v", err)
+		}
+	}
+
+	out, err = exec.Command(cmdSQLite, path, "PRAGMA user_version; SELECT experiment, count(*), max(iteration) FROM results GROUP BY experiment; SELECT count(*) FROM failures;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Could not query database: %v\n%s", err, out)
+	}
+	exp := fmt.Sprintf("%d\nnew|6|4\nold|1|0\n2\n", sqliteVersion)
+	if string(out) != exp {
+		t.Errorf("Unexpected database content\nexpected:\n%s\nwas:\n%s", exp, out)
+	}
+}
+
+func TestSQLiteSinkResume(t *testing.T) {
+	if _, err := exec.LookPath(cmdSQLite); err != nil {
+		t.Skipf("%s not installed", cmdSQLite)
+	}
+	dir, err := ioutil.TempDir("", "goabs-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j, err := OpenJournal(filepath.Join(dir, "out.db.journal"), false)
+	if err != nil {
+		t.Fatalf("Could not open journal: %v", err)
+	}
+	defer j.Close()
+
+	path := filepath.Join(dir, "out.db")
+	s, err := NewSQLiteSink(path, "e", nil)
+	if err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	// the execution of BenchmarkB is interrupted, the one of BenchmarkA completes afterwards
+	b := testRecord()
+	b.Benchmark = "pkg/a_test.go/BenchmarkB"
+	for _, r := range []Record{b, testRecord()} {
+		err = s.Write(r)
+		if err != nil {
+			t.Fatalf("Could not write record: %v", err)
+		}
+	}
+	err = s.(FailureSink).WriteFailure(Failure{Run: 1, SuiteExec: 2, Test: Baseline, Benchmark: b.Benchmark, Reason: PanicFailure})
+	if err != nil {
+		t.Fatalf("Could not write failure: %v", err)
+	}
+	j.Complete(1, 2, Baseline, "pkg/a_test.go::BenchmarkA")
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Could not close sink: %v", err)
+	}
+
+	s, err = NewSQLiteSink(path, "e", j)
+	if err != nil {
+		t.Fatalf("Could not resume database: %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Could not close sink: %v", err)
+	}
+
+	out, err := exec.Command(cmdSQLite, path, "SELECT DISTINCT benchmark FROM results; SELECT count(*) FROM failures;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Could not query database: %v\n%s", err, out)
+	}
+	if string(out) != "pkg/a_test.go/BenchmarkA\n0\n" {
+		t.Errorf("Expected results of incomplete execution to be removed, was %s", out)
+	}
+}
+
+func TestSQLiteSinkFailure(t *testing.T) {
+	if _, err := exec.LookPath(cmdSQLite); err != nil {
+		t.Skipf("%s not installed", cmdSQLite)
+	}
+	dir, err := ioutil.TempDir("", "goabs-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.db")
+	s, err := NewSQLiteSink(path, "e", nil)
+	if err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	err = s.Write(testRecord())
+	if err != nil {
+		t.Fatalf("Could not write record: %v", err)
+	}
+
+	out, err := exec.Command(cmdSQLite, path, "DROP TABLE results;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Could not drop results: %v\n%s", err, out)
+	}
+	err = s.Write(testRecord())
+	if err == nil || !strings.Contains(err.Error(), "results") {
+		t.Errorf("Expected failed insert to be reported by write, was %v", err)
+	}
+	if s.Close() == nil {
+		t.Errorf("Expected failed insert to be reported by close")
+	}
+}
+
+func TestReadSQLite(t *testing.T) {
+	if _, err := exec.LookPath(cmdSQLite); err != nil {
+		t.Skipf("%s not installed", cmdSQLite)
+	}
+	dir, err := ioutil.TempDir("", "goabs-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.db")
+	if _, err := ReadSQLite(path, ""); err == nil {
+		t.Errorf("Expected error for missing database")
+	}
+
+	for _, e := range []string{"a", "b"} {
+		s, err := NewSQLiteSink(path, e, nil)
+		if err != nil {
+			t.Fatalf("Could not open database: %v", err)
+		}
+		r := testRecord()
+		r.Test = e
+		err = s.Write(r)
+		if err != nil {
+			t.Fatalf("Could not write record: %v", err)
+		}
+		err = s.Close()
+		if err != nil {
+			t.Fatalf("Could not close sink: %v", err)
+		}
+	}
+
+	rs, err := ReadSQLite(path, "b")
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	exp := testRecord()
+	exp.Test = "b"
+	if !reflect.DeepEqual(rs, []Record{exp}) {
+		t.Errorf("Unexpected records\nexpected: %v\nwas:      %v", []Record{exp}, rs)
+	}
+
+	if _, err := ReadSQLite(path, ""); err == nil {
+		t.Errorf("Expected error for database with multiple experiments")
+	}
+}
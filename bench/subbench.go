@@ -0,0 +1,260 @@
+package bench
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/executil"
+)
+
+const (
+	subBenchSep     = "/"
+	benchRunMethod  = "Run"
+	cmdArgsListTime = "-benchtime=1x"
+)
+
+// SubBenchmarks replaces every benchmark of benchs that runs sub-benchmarks (b.Run) with its sub-benchmarks.
+// Sub-benchmark names are taken from the string literals passed to b.Run; all other benchmarks (e.g., with computed names,
+// b.Run within loops, or b.Run in helper functions) are executed once (-benchtime=1x) to list them.
+func SubBenchmarks(goRoot, projectRoot string, benchs data.PackageMap) (data.PackageMap, error) {
+	env := executil.ProjectEnv(goRoot, projectRoot)
+	ret := make(data.PackageMap)
+	for pkg, files := range benchs {
+		ret[pkg] = make(data.FileMap)
+		for fn, fs := range files {
+			subs, err := fileSubBenchmarks(filepath.Join(projectRoot, pkg, fn))
+			if err != nil {
+				return nil, err
+			}
+
+			expanded := make(data.File, 0, len(fs))
+			for _, b := range fs {
+				names, ok := subs[b.Name]
+				if !ok {
+					// names not known statically
+					names, err = listSubBenchmarks(env, filepath.Join(projectRoot, pkg), b.Name)
+					if err != nil {
+						fmt.Printf("Could not list sub-benchmarks of %s: %v\n", relBenchName(b), err)
+						names = nil
+					}
+				}
+
+				if len(names) == 0 {
+					expanded = append(expanded, b)
+					continue
+				}
+
+				for _, n := range names {
+					sb := b
+					sb.Name = b.Name + subBenchSep + n
+					expanded = append(expanded, sb)
+				}
+			}
+			ret[pkg][fn] = expanded
+		}
+	}
+	return ret, nil
+}
+
+// fileSubBenchmarks returns the statically known sub-benchmark names of the benchmarks of a file.
+// Only benchmarks that call b.Run with string literals outside of loops are part of the result.
+func fileSubBenchmarks(path string) (map[string][]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string][]string)
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || !strings.HasPrefix(fd.Name.Name, benchFuncPrefix) {
+			continue
+		}
+
+		names, dynamic := subBenchmarks(fd.Body, benchParam(fd.Type))
+		// b.Run might be called by helper functions
+		if dynamic || len(names) == 0 {
+			continue
+		}
+		ret[fd.Name.Name] = names
+	}
+	return ret, nil
+}
+
+// subBenchmarks collects the (nested) sub-benchmarks run with b.Run in body, where b is the name of the *testing.B parameter.
+// dynamic is true if a sub-benchmark name is not a string literal or b.Run is called within a loop.
+func subBenchmarks(body *ast.BlockStmt, b string) (names []string, dynamic bool) {
+	if b == "" || b == "_" {
+		return nil, false
+	}
+
+	seen := make(map[string]int)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if dynamic {
+			return false
+		}
+
+		switch l := n.(type) {
+		case *ast.ForStmt:
+			dynamic = containsBenchRun(l.Body, b)
+			return !dynamic
+		case *ast.RangeStmt:
+			dynamic = containsBenchRun(l.Body, b)
+			return !dynamic
+		}
+
+		c, ok := n.(*ast.CallExpr)
+		if !ok || !isBenchRun(c, b) {
+			return true
+		}
+
+		lit, ok := c.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			dynamic = true
+			return false
+		}
+		name, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			dynamic = true
+			return false
+		}
+		name = uniqueName(seen, rewriteName(name))
+
+		var nested []string
+		if fl, ok := c.Args[1].(*ast.FuncLit); ok {
+			var dyn bool
+			nested, dyn = subBenchmarks(fl.Body, benchParam(fl.Type))
+			if dyn {
+				dynamic = true
+				return false
+			}
+		}
+
+		if len(nested) == 0 {
+			names = append(names, name)
+		}
+		for _, nn := range nested {
+			names = append(names, name+subBenchSep+nn)
+		}
+		return false
+	})
+
+	if dynamic {
+		return nil, true
+	}
+	return names, false
+}
+
+// containsBenchRun reports whether b.Run is called in node
+func containsBenchRun(node ast.Node, b string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && isBenchRun(c, b) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isBenchRun(c *ast.CallExpr, b string) bool {
+	if len(c.Args) != 2 {
+		return false
+	}
+	sel, ok := c.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != benchRunMethod {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == b
+}
+
+func benchParam(ft *ast.FuncType) string {
+	if ft.Params == nil || len(ft.Params.List) == 0 || len(ft.Params.List[0].Names) == 0 {
+		return ""
+	}
+	return ft.Params.List[0].Names[0].Name
+}
+
+// rewriteName rewrites a sub-benchmark name the way the testing package does
+func rewriteName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		case !strconv.IsPrint(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// uniqueName appends a sequence number to duplicate names the way the testing package does
+func uniqueName(seen map[string]int, name string) string {
+	n := seen[name]
+	seen[name] = n + 1
+	if n == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s#%02d", name, n)
+}
+
+func listSubBenchmarks(env []string, dir, bench string) ([]string, error) {
+	procs := benchProcs(env, "")
+	c := exec.Command(executil.GoCommand(env), cmdArgsTest, cmdArgsNoTests, cmdArgsListTime, fmt.Sprintf(cmdArgsCPU, procs), benchArg(bench))
+	c.Dir = dir
+	c.Env = env
+	res, err := c.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("Could not execute '%s': %v\n%s", c.Args, err, res)
+	}
+
+	out, err := parseOutput(string(res), procs)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := bench + subBenchSep
+	names := []string{}
+	seen := make(map[string]struct{})
+	for _, r := range out.Results {
+		if !strings.HasPrefix(r.Name, prefix) {
+			continue
+		}
+		n := strings.TrimPrefix(r.Name, prefix)
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		names = append(names, n)
+	}
+	return names, nil
+}
+
+// benchArg returns the -bench flag that selects exactly the (sub-)benchmark name
+func benchArg(name string) string {
+	return fmt.Sprintf(cmdArgsBench, Pattern(name))
+}
+
+// Pattern returns the -bench pattern that selects exactly the (sub-)benchmark name
+func Pattern(name string) string {
+	parts := strings.Split(name, subBenchSep)
+	for i, p := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(p) + "$"
+	}
+	return strings.Join(parts, subBenchSep)
+}
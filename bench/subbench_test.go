@@ -0,0 +1,104 @@
+package bench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const subBenchFile = `package sample
+
+import (
+	"fmt"
+	"testing"
+)
+
+func BenchmarkPlain(b *testing.B) {}
+
+func BenchmarkStatic(b *testing.B) {
+	b.Run("small input", func(b *testing.B) {})
+	b.Run("large", func(sb *testing.B) {
+		sb.Run("a", func(b *testing.B) {})
+		sb.Run("b", func(b *testing.B) {})
+	})
+	b.Run("large", benchHelper)
+}
+
+func BenchmarkDynamic(b *testing.B) {
+	for _, n := range []int{1, 2} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {})
+	}
+}
+
+func BenchmarkLoop(b *testing.B) {
+	for i := 0; i < 2; i++ {
+		b.Run("same", func(b *testing.B) {})
+	}
+}
+
+func BenchmarkHelper(b *testing.B) {
+	runSubs(b)
+}
+
+func BenchmarkNestedLoop(b *testing.B) {
+	b.Run("outer", func(sb *testing.B) {
+		for _, n := range []string{"a", "b"} {
+			_ = n
+			sb.Run("inner", func(b *testing.B) {})
+		}
+	})
+}
+
+func benchHelper(b *testing.B) {}
+
+func runSubs(b *testing.B) {
+	b.Run("a", benchHelper)
+}
+`
+
+func TestFileSubBenchmarks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-subbench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sample_test.go")
+	err = ioutil.WriteFile(path, []byte(subBenchFile), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := fileSubBenchmarks(path)
+	if err != nil {
+		t.Fatalf("Could not parse file: %v", err)
+	}
+
+	exp := []string{"small_input", "large/a", "large/b", "large#01"}
+	if names := subs["BenchmarkStatic"]; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected sub-benchmarks %v, got %v", exp, names)
+	}
+
+	for _, b := range []string{"BenchmarkPlain", "BenchmarkDynamic", "BenchmarkLoop", "BenchmarkHelper", "BenchmarkNestedLoop"} {
+		if _, ok := subs[b]; ok {
+			t.Fatalf("Expected %s to require listing", b)
+		}
+	}
+}
+
+func TestBenchArg(t *testing.T) {
+	tests := map[string]string{
+		"BenchmarkFib":             "-bench=^BenchmarkFib$",
+		"BenchmarkSizes/n=10":      "-bench=^BenchmarkSizes$/^n=10$",
+		"BenchmarkA/x.y/case#01":   `-bench=^BenchmarkA$/^x\.y$/^case#01$`,
+		"BenchmarkB/(a|b)[1]+c*d?": `-bench=^BenchmarkB$/^\(a\|b\)\[1\]\+c\*d\?$`,
+	}
+
+	for name, exp := range tests {
+		if arg := benchArg(name); arg != exp {
+			t.Fatalf("Expected %s for %s, got %s", exp, name, arg)
+		}
+	}
+}
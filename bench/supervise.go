@@ -0,0 +1,116 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// FailureReason classifies why a benchmark execution did not produce results
+type FailureReason string
+
+const (
+	NoFailure       FailureReason = ""
+	BuildFailure    FailureReason = "build"
+	PanicFailure    FailureReason = "panic"
+	TimeoutFailure  FailureReason = "timeout"
+	ParseFailure    FailureReason = "parse"
+	CanceledFailure FailureReason = "canceled"
+	DeadlockFailure FailureReason = "deadlock"
+)
+
+const (
+	// DefaultKillGrace is the default period between the timeout of an execution and killing it;
+	// go test kills the test binary with SIGQUIT one minute after -timeout, hence the default grace period
+	DefaultKillGrace = 2 * time.Minute
+
+	buildFailedMsg = "[build failed]"
+	setupFailedMsg = "[setup failed]"
+	panicMsg       = "panic: "
+	// reported by the testing package since Go 1.10 (benchTimeoutMsg is reported by go test)
+	testTimeoutMsg = "panic: test timed out after"
+	// reported by the runtime if no goroutine can proceed
+	deadlockMsg = "all goroutines are asleep - deadlock!"
+)
+
+var errHardDeadline = errors.New("Hard deadline exceeded")
+
+// Supervise executes c in its own process group and kills the whole group when the deadline is exceeded or ctx is done.
+// Once c exited or was killed, its output is awaited for at most killGrace (Go 1.20 or newer).
+// It returns the combined output of c.
+func Supervise(ctx context.Context, c *exec.Cmd, deadline, killGrace time.Duration) ([]byte, error) {
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	setProcessGroup(c)
+	setWaitDelay(c, killGrace)
+
+	err := c.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if deadline > 0 {
+		t := time.NewTimer(deadline)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case err = <-done:
+		if isWaitDelay(err) {
+			// the output of processes c left behind is not awaited
+			err = nil
+		}
+		return out.Bytes(), err
+	case <-timeout:
+		err = errHardDeadline
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	killErr := killProcessGroup(c)
+	if killErr != nil {
+		fmt.Printf("Could not kill process group of '%s': %v\n", c.Args, killErr)
+	}
+	<-done
+	return out.Bytes(), err
+}
+
+// isSupervisedFailure is true if err is the result of a supervised execution that was started
+func isSupervisedFailure(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) || err == errHardDeadline ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// classify returns the reason of a failed benchmark execution, given its output and the errors of the execution and of parsing its output
+func classify(out string, execErr, parseErr error) FailureReason {
+	switch {
+	case errors.Is(execErr, context.Canceled) || errors.Is(execErr, context.DeadlineExceeded):
+		return CanceledFailure
+	case execErr == errHardDeadline:
+		return TimeoutFailure
+	case strings.Contains(out, benchTimeoutMsg) || strings.Contains(out, testTimeoutMsg):
+		return TimeoutFailure
+	case strings.Contains(out, buildFailedMsg) || strings.Contains(out, setupFailedMsg):
+		return BuildFailure
+	case execErr != nil && strings.Contains(out, deadlockMsg):
+		return DeadlockFailure
+	case execErr != nil && strings.Contains(out, panicMsg):
+		return PanicFailure
+	case parseErr != nil:
+		return ParseFailure
+	}
+	return NoFailure
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package bench
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(c *exec.Cmd) {}
+
+// killProcessGroup only kills the go command, child processes (e.g., the test binary) might survive
+func killProcessGroup(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
@@ -0,0 +1,33 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	parseErr := resultNotParsable{err: errors.New("No benchmark results in output")}
+	tests := []struct {
+		out      string
+		execErr  error
+		parseErr error
+		exp      FailureReason
+	}{
+		{"BenchmarkA-8 100 10 ns/op\nok\n", nil, nil, NoFailure},
+		{"", errHardDeadline, parseErr, TimeoutFailure},
+		{"panic: test timed out after 1s\n", exitErr, parseErr, TimeoutFailure},
+		{benchTimeoutMsg + "\n", exitErr, parseErr, TimeoutFailure},
+		{"./a.go:3:1: syntax error\nFAIL\tpkg [build failed]\n", exitErr, parseErr, BuildFailure},
+		{"panic: runtime error: index out of range\n", exitErr, parseErr, PanicFailure},
+		{"fatal error: all goroutines are asleep - deadlock!\n", exitErr, parseErr, DeadlockFailure},
+		{"PASS\nok\n", nil, parseErr, ParseFailure},
+		{"", context.DeadlineExceeded, parseErr, CanceledFailure},
+	}
+	for _, tt := range tests {
+		if r := classify(tt.out, tt.execErr, tt.parseErr); r != tt.exp {
+			t.Errorf("Unexpected reason of '%s': expected '%s', was '%s'", tt.out, tt.exp, r)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package bench
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(c *exec.Cmd) error {
+	// the negative pid addresses the process group
+	return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
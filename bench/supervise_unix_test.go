@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package bench
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSuperviseKillsProcessGroup(t *testing.T) {
+	// the child sleep keeps the output open until the whole group is killed
+	c := exec.Command("sh", "-c", "echo started; sleep 30 & wait")
+	start := time.Now()
+	out, err := Supervise(context.Background(), c, 200*time.Millisecond, time.Second)
+	if err != errHardDeadline {
+		t.Fatalf("Expected hard deadline error, was %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Process group not killed, took %s", d)
+	}
+	if string(out) != "started\n" {
+		t.Errorf("Unexpected output '%s'", out)
+	}
+}
+
+func TestSuperviseWaitDelay(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	// the child leaves the process group and keeps the output open after the shell exited
+	c := exec.Command("sh", "-c", "setsid sleep 5 & echo started")
+	start := time.Now()
+	out, err := Supervise(context.Background(), c, time.Minute, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Output awaited after kill grace, took %s", d)
+	}
+	if string(out) != "started\n" {
+		t.Errorf("Unexpected output '%s'", out)
+	}
+}
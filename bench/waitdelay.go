@@ -0,0 +1,21 @@
+//go:build go1.20
+// +build go1.20
+
+package bench
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+)
+
+// setWaitDelay stops waiting for the output of c d after c exited,
+// e.g., if a process outside of its process group inherited the output
+func setWaitDelay(c *exec.Cmd, d time.Duration) {
+	c.WaitDelay = d
+}
+
+// isWaitDelay is true if c exited successfully, but its output was not closed within the wait delay
+func isWaitDelay(err error) bool {
+	return errors.Is(err, exec.ErrWaitDelay)
+}
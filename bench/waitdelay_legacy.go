@@ -0,0 +1,16 @@
+//go:build !go1.20
+// +build !go1.20
+
+package bench
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setWaitDelay is not supported before Go 1.20, Wait waits until the output of c is closed
+func setWaitDelay(c *exec.Cmd, d time.Duration) {}
+
+func isWaitDelay(err error) bool {
+	return false
+}
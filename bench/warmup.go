@@ -0,0 +1,88 @@
+package bench
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/statsutil"
+)
+
+const (
+	defaultSteadyCV     = 0.02
+	minSteadyWindowSize = 2
+)
+
+func withWarmupDefaults(w data.Warmup) data.Warmup {
+	if w.SteadyWindow > 0 && w.SteadyWindow < minSteadyWindowSize {
+		w.SteadyWindow = minSteadyWindowSize
+	}
+	if w.SteadyCV == 0 {
+		w.SteadyCV = defaultSteadyCV
+	}
+	return w
+}
+
+// records converts the results of a benchmark execution into records.
+// Results of the same (sub-)benchmark are numbered in their order of execution and the first ones are tagged as warm-ups.
+func (r *defaultRunner) records(test string, run int, suiteExec int, benchExec int, b data.Function, res Output) []Record {
+	iterations := map[string][]int{}
+	names := []string{}
+	for i, result := range res.Results {
+		if _, ok := iterations[result.Name]; !ok {
+			names = append(names, result.Name)
+		}
+		iterations[result.Name] = append(iterations[result.Name], i)
+	}
+
+	recs := make([]Record, len(res.Results))
+	for _, name := range names {
+		idxs := iterations[name]
+		values := make([]float64, 0, len(idxs))
+		for _, i := range idxs {
+			values = append(values, res.Results[i].Metrics()[timeUnit])
+		}
+		warmups := r.warmups(name, values)
+
+		for it, i := range idxs {
+			result := res.Results[i]
+			recs[i] = Record{
+				Run:         run,
+				SuiteExec:   suiteExec,
+				BenchExec:   benchExec,
+				Test:        test,
+				Benchmark:   filepath.Join(b.Pkg, b.File, result.Name), // result names contain sub-benchmarks
+				Invocations: result.Invocations,
+				Metrics:     result.Metrics(),
+				Iteration:   it,
+				Warmup:      it < warmups,
+			}
+		}
+	}
+	return recs
+}
+
+// warmups returns the number of warm-up iterations of a series of runtime measurements
+func (r *defaultRunner) warmups(name string, values []float64) int {
+	if r.warmup.SteadyWindow == 0 {
+		return r.wi
+	}
+
+	k, ok := steadyState(values, r.warmup.SteadyWindow, r.warmup.SteadyCV)
+	if !ok {
+		fmt.Printf("%s did not reach a steady state, use %d warm-up iterations\n", name, r.wi)
+		return r.wi
+	}
+	return k
+}
+
+// steadyState returns the index of the first window of measurements with a coefficient of variation of at most maxCV
+func steadyState(values []float64, window int, maxCV float64) (int, bool) {
+	for k := 0; k+window <= len(values); k++ {
+		w := values[k : k+window]
+		if statsutil.StdDev(w)/statsutil.Mean(w) <= maxCV {
+			return k, true
+		}
+	}
+	return 0, false
+}
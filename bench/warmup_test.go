@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func TestSteadyState(t *testing.T) {
+	values := []float64{300, 180, 120, 101, 100, 100, 99, 100}
+	k, ok := steadyState(values, 3, 0.02)
+	if !ok || k != 3 {
+		t.Errorf("Expected steady state at 3, was %d (%t)", k, ok)
+	}
+
+	_, ok = steadyState([]float64{300, 100, 300, 100}, 2, 0.02)
+	if ok {
+		t.Errorf("Expected no steady state")
+	}
+}
+
+func TestRecordsWarmup(t *testing.T) {
+	res := Output{Results: []Result{
+		{Name: "BenchmarkA", Values: []Value{{Value: 200, Unit: timeUnit}}},
+		{Name: "BenchmarkB", Values: []Value{{Value: 10, Unit: timeUnit}}},
+		{Name: "BenchmarkA", Values: []Value{{Value: 100, Unit: timeUnit}}},
+		{Name: "BenchmarkA", Values: []Value{{Value: 100, Unit: timeUnit}}},
+	}}
+	b := data.Function{Pkg: "pkg", File: "a_test.go", Name: "BenchmarkA"}
+
+	r := &defaultRunner{wi: 1, warmup: withWarmupDefaults(data.Warmup{})}
+	recs := r.records(Baseline, 1, 1, 1, b, res)
+	expIt := []int{0, 0, 1, 2}
+	expWarmup := []bool{true, true, false, false}
+	for i, rec := range recs {
+		if rec.Iteration != expIt[i] || rec.Warmup != expWarmup[i] {
+			t.Errorf("Unexpected record %d: %+v", i, rec)
+		}
+	}
+
+	r.warmup = withWarmupDefaults(data.Warmup{SteadyWindow: 2})
+	recs = r.records(Baseline, 1, 1, 1, b, res)
+	if !recs[0].Warmup || recs[2].Warmup || recs[3].Warmup {
+		t.Errorf("Expected only the first iteration of BenchmarkA to be a warm-up: %+v", recs)
+	}
+	// BenchmarkB has a single iteration and no steady state, fall back to wi
+	if !recs[1].Warmup {
+		t.Errorf("Expected fallback to wi for BenchmarkB: %+v", recs[1])
+	}
+}
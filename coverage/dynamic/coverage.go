@@ -0,0 +1,201 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/executil"
+)
+
+const (
+	cmdArgsTest      = "test"
+	cmdArgsNoTests   = "-run=^$"
+	cmdArgsBench     = "-bench=%s"
+	cmdArgsBenchTime = "-benchtime=1x"
+	cmdArgsTimeout   = "-timeout=%s"
+	cmdArgsCoverMode = "-covermode=set"
+	cmdArgsCoverPkg  = "-coverpkg=%s"
+	cmdArgsCoverOut  = "-coverprofile=%s"
+
+	allPkgs = "..."
+)
+
+// Matrix relates benchmarks to the functions they execute.
+// Benchmarks are named as in the results of dynamic runs (package/file/benchmark), functions as data.Function.String.
+type Matrix struct {
+	Benchmarks map[string][]string `json:"benchmarks"`
+	Functions  map[string][]string `json:"functions"`
+}
+
+func newMatrix() Matrix {
+	return Matrix{
+		Benchmarks: map[string][]string{},
+		Functions:  map[string][]string{},
+	}
+}
+
+func (m Matrix) add(bench, fun string) {
+	m.Benchmarks[bench] = append(m.Benchmarks[bench], fun)
+	m.Functions[fun] = append(m.Functions[fun], bench)
+}
+
+// Coverage executes every benchmark once (-benchtime=1x) with coverage instrumentation of all project packages
+// and relates it to the functions of funs whose bodies it executed.
+// Benchmarks that fail are reported and cover no function.
+// Like in dynamic runs, the whole process group of an execution is killed killGrace after timeout (default bench.DefaultKillGrace).
+func Coverage(goRoot, projectRoot string, benchs data.PackageMap, funs []data.Function, timeout, killGrace time.Duration) (Matrix, error) {
+	m := newMatrix()
+	if killGrace == 0 {
+		killGrace = bench.DefaultKillGrace
+	}
+
+	index, err := newFunctionIndex(projectRoot, funs)
+	if err != nil {
+		return m, err
+	}
+
+	rootPath, err := executil.ImportPath(projectRoot, "")
+	if err != nil {
+		return m, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "goabs-coverage-")
+	if err != nil {
+		return m, err
+	}
+	defer os.RemoveAll(tmpDir)
+	profile := filepath.Join(tmpDir, "cover.out")
+
+	env := executil.ProjectEnv(goRoot, projectRoot)
+	for pkg, files := range benchs {
+		for file, fs := range files {
+			for _, b := range fs {
+				benchName := filepath.Join(pkg, file, b.Name)
+				fmt.Printf("### Coverage of Benchmark: %s\n", benchName)
+
+				c := exec.Command(
+					executil.GoCommand(env),
+					cmdArgsTest,
+					cmdArgsNoTests,
+					fmt.Sprintf(cmdArgsBench, bench.Pattern(b.Name)),
+					cmdArgsBenchTime,
+					fmt.Sprintf(cmdArgsTimeout, timeout),
+					cmdArgsCoverMode,
+					fmt.Sprintf(cmdArgsCoverPkg, path.Join(rootPath, allPkgs)),
+					fmt.Sprintf(cmdArgsCoverOut, profile),
+				)
+				c.Dir = filepath.Join(projectRoot, pkg)
+				c.Env = env
+
+				res, err := bench.Supervise(context.Background(), c, timeout+killGrace, killGrace)
+				if err != nil {
+					fmt.Printf("Error while executing command '%s': %v\n%s\n", c.Args, err, res)
+					continue
+				}
+
+				covered, err := coveredFunctions(profile, index)
+				if err != nil {
+					return m, err
+				}
+				for _, f := range covered {
+					m.add(benchName, f)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func coveredFunctions(profile string, index functionIndex) ([]string, error) {
+	f, err := os.Open(profile)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open coverage profile: %v", err)
+	}
+	defer f.Close()
+
+	blocks, err := parseProfile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	covered := map[string]struct{}{}
+	for _, b := range blocks {
+		if b.count == 0 {
+			continue
+		}
+		if fun, ok := index.lookup(b); ok {
+			covered[fun] = struct{}{}
+		}
+	}
+
+	ret := make([]string, 0, len(covered))
+	for fun := range covered {
+		ret = append(ret, fun)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// functionIndex maps files (import path) to their functions
+type functionIndex map[string][]data.Function
+
+func newFunctionIndex(projectRoot string, funs []data.Function) (functionIndex, error) {
+	index := functionIndex{}
+	for _, f := range funs {
+		ip, err := executil.ImportPath(projectRoot, f.Pkg)
+		if err != nil {
+			return nil, err
+		}
+		file := path.Join(ip, f.File)
+		index[file] = append(index[file], f)
+	}
+	return index, nil
+}
+
+// lookup returns the function containing block b
+func (i functionIndex) lookup(b block) (string, bool) {
+	for _, f := range i[b.file] {
+		if b.startLine >= f.StartLine && b.endLine <= f.EndLine {
+			return f.String(), true
+		}
+	}
+	return "", false
+}
+
+// Report is the coverage-based ABS: the fraction of functions executed by at least one benchmark.
+type Report struct {
+	Matrix
+	Uncovered []string `json:"uncovered"`
+	Covered   int      `json:"covered"`
+	Total     int      `json:"total"`
+	Score     float64  `json:"score"`
+}
+
+// Score computes the coverage-based ABS of the functions funs.
+func Score(m Matrix, funs []string) Report {
+	r := Report{
+		Matrix:    m,
+		Uncovered: []string{},
+		Total:     len(funs),
+	}
+	for _, f := range funs {
+		if len(m.Functions[f]) > 0 {
+			r.Covered++
+		} else {
+			r.Uncovered = append(r.Uncovered, f)
+		}
+	}
+	if r.Total > 0 {
+		r.Score = float64(r.Covered) / float64(r.Total)
+	}
+	return r
+}
@@ -0,0 +1,77 @@
+package dynamic
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const profile = `mode: set
+example.com/sample/sub/calc.go:3.22,5.25 2 1
+example.com/sample/sub/calc.go:5.25,7.3 1 1
+example.com/sample/sub/calc.go:13.27,13.37 1 0
+example.com/sample/sub/extra.go:3.20,3.36 1 1
+`
+
+func TestParseProfile(t *testing.T) {
+	blocks, err := parseProfile(strings.NewReader(profile))
+	if err != nil {
+		t.Fatalf("Could not parse profile: %v", err)
+	}
+
+	if len(blocks) != 4 {
+		t.Fatalf("Expected 4 blocks, got %d", len(blocks))
+	}
+	exp := block{file: "example.com/sample/sub/calc.go", startLine: 5, endLine: 7, count: 1}
+	if blocks[1] != exp {
+		t.Fatalf("Expected block %v, got %v", exp, blocks[1])
+	}
+
+	_, err = parseProfile(strings.NewReader("example.com/sample/sub/calc.go 1 1\n"))
+	if err == nil {
+		t.Fatalf("Expected error for invalid block")
+	}
+}
+
+func TestCoveredFunctions(t *testing.T) {
+	index := functionIndex{
+		"example.com/sample/sub/calc.go": []data.Function{
+			{Pkg: "sub", File: "calc.go", Name: "Fib", StartLine: 3, EndLine: 9},
+			{Pkg: "sub", File: "calc.go", Name: "Add", Receiver: "*Acc", StartLine: 13, EndLine: 13},
+		},
+	}
+
+	f, err := ioutil.TempFile("", "goabs-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(profile)
+	f.Close()
+
+	covered, err := coveredFunctions(f.Name(), index)
+	if err != nil {
+		t.Fatalf("Could not read covered functions: %v", err)
+	}
+	exp := []string{"sub.{calc.go}.Fib"}
+	if !reflect.DeepEqual(covered, exp) {
+		t.Fatalf("Expected covered functions %v, got %v", exp, covered)
+	}
+
+	m := newMatrix()
+	for _, f := range covered {
+		m.add("/sub/calc_test.go/BenchmarkFib", f)
+	}
+
+	r := Score(m, []string{"sub.{calc.go}.Fib", "sub.{calc.go}.(*Acc).Add"})
+	if r.Covered != 1 || r.Total != 2 || r.Score != 0.5 {
+		t.Fatalf("Expected 1 of 2 functions covered, got %d of %d (%f)", r.Covered, r.Total, r.Score)
+	}
+	if !reflect.DeepEqual(r.Uncovered, []string{"sub.{calc.go}.(*Acc).Add"}) {
+		t.Fatalf("Unexpected uncovered functions %v", r.Uncovered)
+	}
+}
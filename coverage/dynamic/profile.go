@@ -0,0 +1,82 @@
+package dynamic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const profileModePrefix = "mode:"
+
+// block is a code block of a coverage profile (go test -coverprofile)
+type block struct {
+	file      string // import path of the file
+	startLine int
+	endLine   int
+	count     int
+}
+
+// parseProfile parses blocks of the form "example.com/pkg/file.go:3.22,5.2 1 1"
+func parseProfile(r io.Reader) ([]block, error) {
+	blocks := []block{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, profileModePrefix) {
+			continue
+		}
+
+		b, err := parseBlock(l)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, s.Err()
+}
+
+func parseBlock(l string) (block, error) {
+	i := strings.LastIndex(l, ":")
+	if i < 0 {
+		return block{}, fmt.Errorf("Invalid coverage block '%s'", l)
+	}
+
+	fields := strings.Fields(l[i+1:])
+	if len(fields) != 3 {
+		return block{}, fmt.Errorf("Invalid coverage block '%s'", l)
+	}
+	pos := strings.Split(fields[0], ",")
+	if len(pos) != 2 {
+		return block{}, fmt.Errorf("Invalid coverage block '%s'", l)
+	}
+
+	start, err := line(pos[0])
+	if err != nil {
+		return block{}, err
+	}
+	end, err := line(pos[1])
+	if err != nil {
+		return block{}, err
+	}
+	count, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return block{}, fmt.Errorf("Invalid count of coverage block '%s': %v", l, err)
+	}
+
+	return block{
+		file:      l[:i],
+		startLine: start,
+		endLine:   end,
+		count:     count,
+	}, nil
+}
+
+// line returns the line of a position of the form "line.column"
+func line(pos string) (int, error) {
+	if i := strings.Index(pos, "."); i >= 0 {
+		pos = pos[:i]
+	}
+	return strconv.Atoi(pos)
+}
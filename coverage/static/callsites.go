@@ -7,11 +7,13 @@ import (
 
 	"github.com/sealuzh/goabs/coverage/callsite"
 	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/executil"
 	"golang.org/x/tools/go/loader"
 )
 
 const (
 	defaultErrors = 10
+	testPkgSuffix = "_test"
 )
 
 var _ callsite.Finder = &staticCallSiteFinder{}
@@ -33,6 +35,34 @@ func NewStaticCallSiteFinder(path string, gopath string, recursivePackages bool,
 	}
 }
 
+// NewProjectCallSiteFinder creates a finder for the packages pkgs (import paths) of the project in projectRoot.
+// Contrary to NewStaticCallSiteFinder, it resolves imports of module projects.
+func NewProjectCallSiteFinder(projectRoot string, pkgs []string, excludeTests bool) *staticCallSiteFinder {
+	pkgsMap := map[string]struct{}{}
+	for _, pkg := range pkgs {
+		pkgsMap[pkg] = struct{}{}
+		if !excludeTests {
+			// external test packages
+			pkgsMap[pkg+testPkgSuffix] = struct{}{}
+		}
+	}
+
+	var gopath string
+	if !executil.IsModule(projectRoot) {
+		gopath = executil.GoPath(projectRoot)
+	}
+
+	return &staticCallSiteFinder{
+		cs:           map[string]callsite.List{},
+		csCounts:     map[string]int{},
+		gopath:       gopath,
+		dir:          projectRoot,
+		pkgs:         pkgs,
+		pkgsMap:      pkgsMap,
+		excludeTests: excludeTests,
+	}
+}
+
 type staticCallSiteFinder struct {
 	parsed       bool
 	count        int
@@ -40,6 +70,7 @@ type staticCallSiteFinder struct {
 	csCounts     map[string]int
 	csCountTotal int
 	gopath       string
+	dir          string // directory go commands are executed in (module projects)
 	pkgs         []string
 	pkgsMap      map[string]struct{}
 	excludeTests bool
@@ -107,7 +138,15 @@ func (f *staticCallSiteFinder) Parse() error {
 	var conf loader.Config
 	conf.AllowErrors = true
 	conf.Build = &build.Default
-	conf.Build.GOPATH = f.gopath
+	if f.dir != "" {
+		ctx := build.Default
+		ctx.Dir = f.dir
+		conf.Build = &ctx
+		conf.Cwd = f.dir
+	}
+	if f.gopath != "" {
+		conf.Build.GOPATH = f.gopath
+	}
 	//conf.Build.UseAllFiles = true // adds problems for type resolver
 	//conf.CreateFromFilenames(filepath.Join(gopath, pkg), fileName)
 	for _, pkg := range f.pkgs {
@@ -8,28 +8,386 @@ import (
 type Config struct {
 	Project       string        `json:"project"`
 	DynamicConfig DynamicConfig `json:"dynamic"`
+	ABSConfig     ABSConfig     `json:"abs"`
+	Selection     Selection     `json:"select"`
+	Stability     Stability     `json:"stability"`
 	TraceLibrary  string        `json:"trace_lib"`
+	TraceProjects []string      `json:"trace_projects"`
+	TraceCallers  bool          `json:"trace_callers"`
+	TraceScope    TraceScope    `json:"trace_scope"`
 	ClearFolder   string        `json:"clear"`
 	FetchDeps     bool          `json:"fetch_deps"`
 	GoRoot        string        `json:"go_root"`
 }
 
 type DynamicConfig struct {
-	BenchmarkRegex        string     `json:"bench_regex"`
-	WarmupIterations      int        `json:"wi"`
-	MeasurementIterations int        `json:"i"`
-	BenchTime             Duration   `json:"bench_time"`
-	BenchTimeout          Duration   `json:"bench_timeout"`
-	BenchDuration         Duration   `json:"bench_duration"`
-	BenchMem              bool       `json:"bench_mem"`
-	Runs                  int        `json:"runs"`
-	RunsTimeout           Duration   `json:"runs_timeout"`
-	RunDuration           Duration   `json:"run_duration"`
-	Profile               Profile    `json:"profile"`
-	ProfileDir            string     `json:"profile_dir"`
-	Regression            float32    `json:"regression"`
-	Functions             []Function `json:"functions"`
-	Rmit                  bool       `json:"rmit"`
+	BenchmarkRegex        string         `json:"bench_regex"`
+	SubBenchmarks         bool           `json:"sub_benchs"`
+	WarmupIterations      int            `json:"wi"`
+	MeasurementIterations int            `json:"i"`
+	BenchTime             Duration       `json:"bench_time"`
+	BenchTimeout          Duration       `json:"bench_timeout"`
+	KillGrace             Duration       `json:"kill_grace"`
+	Penalty               PenaltyPolicy  `json:"penalty"`
+	PenaltyStrikes        int            `json:"penalty_strikes"`
+	BenchDuration         Duration       `json:"bench_duration"`
+	BenchMem              bool           `json:"bench_mem"`
+	Runs                  int            `json:"runs"`
+	RunsTimeout           Duration       `json:"runs_timeout"`
+	RunDuration           Duration       `json:"run_duration"`
+	Profile               Profile        `json:"profile"`
+	ProfileDir            string         `json:"profile_dir"`
+	Regression            float32        `json:"regression"`
+	RegressionType        RegressionType `json:"regression_type"`
+	RegressionDelay       Duration       `json:"regression_delay"`
+	RegressionAlloc       int            `json:"regression_alloc"`
+	Functions             []Function     `json:"functions"`
+	Rmit                  bool           `json:"rmit"`
+	Order                 Order          `json:"order"`
+	OrderSeed             int64          `json:"order_seed"`
+	Isolation             Isolation      `json:"isolation"`
+	Workspace             string         `json:"workspace"`
+	Compile               bool           `json:"compile"`
+	Workers               int            `json:"workers"`
+	CPUSets               []string       `json:"cpu_sets"`
+	OutFormat             OutFormat      `json:"out_format"`
+	Experiment            string         `json:"experiment"`
+	Adaptive              Adaptive       `json:"adaptive"`
+	Warmup                Warmup         `json:"warmup"`
+	Preflight             Preflight      `json:"preflight"`
+}
+
+// Preflight configures the checks of the benchmarking host for measurement hazards before a dynamic run (Linux only).
+// With Strict, the run is aborted if a check fails, otherwise failed checks are reported as warnings.
+type Preflight struct {
+	Skip          bool     `json:"skip"`
+	Strict        bool     `json:"strict"`
+	MaxLoad       float64  `json:"max_load"`        // maximum 1-minute load average
+	MaxProcessCPU float64  `json:"max_process_cpu"` // maximum CPU utilisation of other processes (0.1 = 10% of one CPU)
+	Interval      Duration `json:"interval"`        // sampling interval of swap activity and processes
+}
+
+// Warmup configures how warm-up iterations are handled.
+// With steady-state detection (SteadyWindow > 0), the iterations of a benchmark execution before the first
+// SteadyWindow consecutive iterations with a coefficient of variation of at most SteadyCV are warm-ups (instead of wi).
+type Warmup struct {
+	Drop         bool    `json:"drop"`
+	SteadyWindow int     `json:"steady_window"`
+	SteadyCV     float64 `json:"steady_cv"`
+}
+
+// Adaptive configures adaptive stopping: a benchmark is executed repeatedly until the confidence interval
+// of its statistic is narrower than CIWidth (relative to the statistic) or a budget is exhausted.
+// Adaptive stopping is disabled if CIWidth is 0.
+type Adaptive struct {
+	CIWidth         float64   `json:"ci_width"`
+	Statistic       Statistic `json:"statistic"`
+	Confidence      float64   `json:"confidence"`
+	Metric          string    `json:"metric"`
+	MinMeasurements int       `json:"min_measurements"`
+	MaxMeasurements int       `json:"max_measurements"`
+	MaxDuration     Duration  `json:"max_duration"`
+}
+
+// Statistic is a summary statistic of benchmark measurements.
+type Statistic string
+
+const (
+	MeanStatistic   Statistic = "mean"
+	MedianStatistic Statistic = "median"
+)
+
+var allStatistics = [...]string{string(MeanStatistic), string(MedianStatistic)}
+
+func (st *Statistic) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*st = MeanStatistic
+		return nil
+	}
+
+	for _, statistic := range allStatistics {
+		if s == statistic {
+			*st = Statistic(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid statistic '%s'", s)
+}
+
+// DefaultRegression is the regression introduced into functions without own regression settings.
+func (c DynamicConfig) DefaultRegression() Regression {
+	t := c.RegressionType
+	if t == "" {
+		t = RelativeRegression
+	}
+	return Regression{
+		Type:   t,
+		Factor: c.Regression,
+		Delay:  c.RegressionDelay,
+		Alloc:  c.RegressionAlloc,
+	}
+}
+
+// OutFormat is the format benchmark results are written in.
+type OutFormat string
+
+const (
+	CSVFormat    OutFormat = "csv"
+	JSONLFormat  OutFormat = "jsonl"
+	SQLiteFormat OutFormat = "sqlite"
+)
+
+var allOutFormats = [...]string{string(CSVFormat), string(JSONLFormat), string(SQLiteFormat)}
+
+func (f *OutFormat) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*f = CSVFormat
+		return nil
+	}
+
+	for _, format := range allOutFormats {
+		if s == format {
+			*f = OutFormat(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid output format '%s'", s)
+}
+
+// Order defines the order benchmarks and variants (baseline and altered functions) are executed in.
+type Order string
+
+const (
+	// DeterministicOrder executes the benchmarks sorted by package, file, and declaration and the variants in configuration order
+	DeterministicOrder Order = "deterministic"
+	// ShuffleOrder shuffles the benchmarks of every suite execution and the altered functions of every run
+	ShuffleOrder Order = "shuffle"
+	// RMITOrder executes randomised multiple interleaved trials: every benchmark is executed for the baseline
+	// and all altered functions (in random order) before the next (random) benchmark
+	RMITOrder Order = "rmit"
+)
+
+var allOrders = [...]string{string(DeterministicOrder), string(ShuffleOrder), string(RMITOrder)}
+
+func (o *Order) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*o = DeterministicOrder
+		return nil
+	}
+
+	for _, order := range allOrders {
+		if s == order {
+			*o = Order(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid order '%s'", s)
+}
+
+// PenaltyPolicy defines when a failing benchmark is not executed anymore.
+type PenaltyPolicy string
+
+const (
+	// PermanentPenalty excludes a benchmark after its first failure
+	PermanentPenalty PenaltyPolicy = "permanent"
+	// StrikesPenalty excludes a benchmark after penalty_strikes failures
+	StrikesPenalty PenaltyPolicy = "strikes"
+	// RetryPenalty excludes a benchmark for the rest of the run it failed in
+	RetryPenalty PenaltyPolicy = "retry"
+)
+
+var allPenaltyPolicies = [...]string{string(PermanentPenalty), string(StrikesPenalty), string(RetryPenalty)}
+
+func (p *PenaltyPolicy) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*p = PermanentPenalty
+		return nil
+	}
+
+	for _, policy := range allPenaltyPolicies {
+		if s == policy {
+			*p = PenaltyPolicy(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid penalty policy '%s'", s)
+}
+
+// Isolation defines how regressions are introduced into the project under test.
+type Isolation string
+
+const (
+	// GitIsolation rewrites the project files in place and restores them with git reset --hard (opt-in)
+	GitIsolation Isolation = "git"
+	// OverlayIsolation writes altered files to a workspace and builds them with go build's -overlay flag (default)
+	OverlayIsolation Isolation = "overlay"
+)
+
+var allIsolations = [...]string{string(GitIsolation), string(OverlayIsolation)}
+
+func (i *Isolation) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*i = OverlayIsolation
+		return nil
+	}
+
+	for _, isolation := range allIsolations {
+		if s == isolation {
+			*i = Isolation(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid isolation '%s'", s)
+}
+
+// ABSConfig configures how the API Benchmarking Score is computed from dynamic results.
+type ABSConfig struct {
+	Test      StatTest `json:"test"`
+	Alpha     float64  `json:"alpha"`
+	MinChange float64  `json:"min_change"`
+	Metric    string   `json:"metric"`
+	RunsRatio float64  `json:"runs_ratio"`
+}
+
+// Stability configures when a benchmark is considered unstable (goabs stability).
+type Stability struct {
+	Metric     string  `json:"metric"`
+	MaxCV      float64 `json:"max_cv"`
+	MaxCIWidth float64 `json:"max_ci_width"`
+	Confidence float64 `json:"confidence"`
+	Resamples  int     `json:"resamples"`
+	Seed       int64   `json:"seed"`
+}
+
+// Selection configures which functions are selected for regression injection (goabs select).
+type Selection struct {
+	Exported  bool     `json:"exported"`
+	Reachable bool     `json:"reachable"`
+	Sampling  Sampling `json:"sampling"`
+	N         int      `json:"n"`
+	Seed      int64    `json:"seed"`
+}
+
+// Sampling is the strategy functions are sampled with.
+type Sampling string
+
+const (
+	RandomSampling     Sampling = "random"
+	StratifiedSampling Sampling = "stratified"
+	TopSampling        Sampling = "top"
+)
+
+var allSamplings = [...]string{string(RandomSampling), string(StratifiedSampling), string(TopSampling)}
+
+func (sa *Sampling) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*sa = RandomSampling
+		return nil
+	}
+
+	for _, sampling := range allSamplings {
+		if s == sampling {
+			*sa = Sampling(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid sampling '%s'", s)
+}
+
+// TraceScope defines which functions of a trace library are traced; every scope includes the previous ones.
+type TraceScope string
+
+const (
+	// ExportedScope traces exported functions and exported methods of exported types
+	ExportedScope TraceScope = "exported"
+	// FunctionsScope traces all named functions and all methods of exported types
+	FunctionsScope TraceScope = "functions"
+	// MethodsScope traces methods of unexported types
+	MethodsScope TraceScope = "methods"
+	// LiteralsScope traces function literals, identified by file and line
+	LiteralsScope TraceScope = "literals"
+)
+
+var allTraceScopes = [...]string{string(ExportedScope), string(FunctionsScope), string(MethodsScope), string(LiteralsScope)}
+
+func (ts *TraceScope) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*ts = ExportedScope
+		return nil
+	}
+
+	for _, scope := range allTraceScopes {
+		if s == scope {
+			*ts = TraceScope(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid trace scope '%s'", s)
+}
+
+// Includes reports whether ts includes the scope o.
+func (ts TraceScope) Includes(o TraceScope) bool {
+	return ts.level() >= o.level()
+}
+
+func (ts TraceScope) level() int {
+	for i, scope := range allTraceScopes {
+		if string(ts) == scope {
+			return i
+		}
+	}
+	// default scope
+	return 0
+}
+
+// StatTest is the statistical test used to decide whether a benchmark detects a regression.
+type StatTest string
+
+const (
+	MannWhitneyU  StatTest = "mwu"
+	WelchTTest    StatTest = "welch"
+	RelativeDelta StatTest = "relative"
+)
+
+var allStatTests = [...]string{string(MannWhitneyU), string(WelchTTest), string(RelativeDelta)}
+
+func (t *StatTest) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*t = MannWhitneyU
+		return nil
+	}
+
+	for _, test := range allStatTests {
+		if s == test {
+			*t = StatTest(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid statistical test '%s'", s)
 }
 
 type Duration time.Duration
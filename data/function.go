@@ -2,8 +2,14 @@ package data
 
 import (
 	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
 )
 
+// literalPrefix prefixes the names of function literals, which are identified by file and line (e.g., "func:42")
+const literalPrefix = "func:"
+
 // Function represents a Go function.
 // It does not contain function parameters nor return types, as they are not part of the function signature.
 // The method receiver is part of the signature (if available).
@@ -14,6 +20,8 @@ type Function struct {
 	Receiver  string `json:"recv"`
 	StartLine int    `json:"start_line"`
 	EndLine   int    `json:"end_line"`
+	// Regression overrides the configured default regression of dynamic runs
+	Regression *Regression `json:"regression,omitempty"`
 }
 
 func (f Function) String() string {
@@ -30,6 +38,37 @@ func (f Function) String() string {
 	return s
 }
 
+// Literal returns the function literal of file pkg/file spanning the lines start to end.
+func Literal(pkg, file string, start, end int) Function {
+	return Function{
+		Pkg:       pkg,
+		File:      file,
+		Name:      fmt.Sprintf("%s%d", literalPrefix, start),
+		StartLine: start,
+		EndLine:   end,
+	}
+}
+
+// LiteralLine returns the line the function literal f starts at; ok is false if f is not a function literal.
+func (f Function) LiteralLine() (line int, ok bool) {
+	if f.Receiver != "" || !strings.HasPrefix(f.Name, literalPrefix) {
+		return 0, false
+	}
+	line, err := strconv.Atoi(strings.TrimPrefix(f.Name, literalPrefix))
+	if err != nil {
+		return 0, false
+	}
+	return line, true
+}
+
+// IsExported reports whether f is part of the public API, i.e., an exported function or an exported method of an exported type.
+func (f Function) IsExported() bool {
+	if f.Receiver != "" && !ast.IsExported(strings.TrimPrefix(f.Receiver, "*")) {
+		return false
+	}
+	return ast.IsExported(f.Name)
+}
+
 func (f Function) StringWithLines() string {
 	s := f.String()
 	if f.StartLine != -1 && f.EndLine != -1 {
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"fmt"
+)
+
+// RegressionType defines how a regression is introduced into a function.
+type RegressionType string
+
+const (
+	// RelativeRegression sleeps proportionally to the function's runtime (Factor)
+	RelativeRegression RegressionType = "relative"
+	// BusyRegression spins the CPU proportionally to the function's runtime (Factor)
+	BusyRegression RegressionType = "busy"
+	// ConstantRegression sleeps for a constant duration (Delay)
+	ConstantRegression RegressionType = "constant"
+	// AllocRegression allocates a heap buffer (Alloc bytes) on every call
+	AllocRegression RegressionType = "alloc"
+	// LockRegression serialises all calls with a package-level mutex, held for Delay
+	LockRegression RegressionType = "lock"
+)
+
+var allRegressionTypes = [...]string{string(RelativeRegression), string(BusyRegression), string(ConstantRegression), string(AllocRegression), string(LockRegression)}
+
+func (t *RegressionType) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	s = s[1 : len(s)-1]
+
+	if s == "" {
+		*t = RelativeRegression
+		return nil
+	}
+
+	for _, rt := range allRegressionTypes {
+		if s == rt {
+			*t = RegressionType(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid regression type '%s'", s)
+}
+
+// Regression configures the regression introduced into a function.
+type Regression struct {
+	Type   RegressionType `json:"type"`
+	Factor float32        `json:"factor"`
+	Delay  Duration       `json:"delay"`
+	Alloc  int            `json:"alloc"`
+}
+
+// Or returns r with all unset attributes taken from def.
+func (r Regression) Or(def Regression) Regression {
+	if r.Type == "" {
+		r.Type = def.Type
+	}
+	if r.Factor == 0 {
+		r.Factor = def.Factor
+	}
+	if r.Delay == 0 {
+		r.Delay = def.Delay
+	}
+	if r.Alloc == 0 {
+		r.Alloc = def.Alloc
+	}
+	return r
+}
+
+// Validate reports attributes that prevent r from slowing down a function, e.g., a relative regression without factor.
+func (r Regression) Validate() error {
+	switch r.Type {
+	case RelativeRegression, BusyRegression, "":
+		if r.Factor <= 0 {
+			return fmt.Errorf("Regression type '%s' requires a positive factor", r.Type)
+		}
+	case ConstantRegression, LockRegression:
+		if r.Delay <= 0 {
+			return fmt.Errorf("Regression type '%s' requires a positive delay", r.Type)
+		}
+	case AllocRegression:
+		if r.Alloc <= 0 {
+			return fmt.Errorf("Regression type '%s' requires a positive allocation size", r.Type)
+		}
+	default:
+		return fmt.Errorf("Invalid regression type '%s'", r.Type)
+	}
+	return nil
+}
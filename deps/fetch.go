@@ -13,8 +13,7 @@ func Fetch(projectPath, goRoot string) error {
 		return err
 	}
 	depMgr := Manager(projectPath)
-	gopath := executil.GoPath(projectPath)
-	env := executil.Env(goRoot, gopath)
+	env := executil.ProjectEnv(goRoot, projectPath)
 	out, err := depMgr.FetchDeps(env)
 	if err != nil {
 		return fmt.Errorf("Error while fetching dependencies for '%s': %v\n\nOut: %s", projectPath, err, string(out))
@@ -28,6 +28,7 @@ const (
 	gogradle DepMgr = "Gogradle"
 	gpm      DepMgr = "gpm"
 	glock    DepMgr = "glock"
+	mod      DepMgr = "mod"
 )
 
 const (
@@ -41,6 +42,8 @@ const (
 	goList       = "list"
 	goAllPkgs    = "./..."
 	goListNoDeps = "go list ./... | grep -v -E 'vendor|_vendor|.vendor|_workspace'"
+	goMod        = "mod"
+	goModDl      = "download"
 )
 
 var depFolders = []string{"vendor", "_vendor", ".vendor", "_workspace"}
@@ -52,8 +55,11 @@ func (d DepMgr) String() string {
 }
 
 func (d DepMgr) FetchDeps(env []string) ([]byte, error) {
-	if d == get {
+	switch d {
+	case get:
 		return execGoGet(env)
+	case mod:
+		return execGoModDownload(env)
 	}
 
 	var c *exec.Cmd
@@ -116,6 +122,14 @@ Loop:
 	return outBuf.Bytes(), nil
 }
 
+func execGoModDownload(env []string) ([]byte, error) {
+	c := exec.Command(executil.GoCommand(env), goMod, goModDl)
+	if len(env) > 0 {
+		c.Env = env
+	}
+	return c.CombinedOutput()
+}
+
 func depsFolderInPath(path string) bool {
 	goPath := executil.GoPath(path)
 	for _, f := range depFolders {
@@ -155,6 +169,8 @@ func (d DepMgr) installCmd() string {
 		cmd = "gpm install"
 	case glock:
 		cmd = "glock sync"
+	case mod:
+		cmd = fmt.Sprintf("%s %s %s", goCmd, goMod, goModDl)
 
 	case get:
 		fallthrough
@@ -167,6 +183,11 @@ func (d DepMgr) installCmd() string {
 // based on https://github.com/blindpirate/report-of-build-tools-for-java-and-golang and
 // https://github.com/golang/go/wiki/PackageManagementTools
 func Manager(projectPath string) DepMgr {
+	// Go modules
+	if executil.IsModule(projectPath) {
+		return mod
+	}
+
 	// dep
 	p := filepath.Join(projectPath, "Gopkg.lock")
 	_, err := os.Stat(p)
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -12,16 +11,35 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/sealuzh/goabs/abs"
 	"github.com/sealuzh/goabs/bench"
+	dyncov "github.com/sealuzh/goabs/coverage/dynamic"
 	"github.com/sealuzh/goabs/data"
 	"github.com/sealuzh/goabs/deps"
+	"github.com/sealuzh/goabs/manifest"
+	"github.com/sealuzh/goabs/preflight"
+	"github.com/sealuzh/goabs/selection"
+	"github.com/sealuzh/goabs/stability"
 	"github.com/sealuzh/goabs/trans/count"
 	"github.com/sealuzh/goabs/trans/regression"
 )
 
 const (
+	journalSuffix   = ".journal"
+	failuresSuffix  = ".failures"
+	binDirPrefix    = "goabs-bin"
+	orderSuffix     = ".order"
+	manifestSuffix  = ".manifest.json"
+	preflightSuffix = ".preflight"
+	rankingSuffix   = ".ranking.json"
+	selectCmd       = "select"
+	coverageCmd     = "coverage"
+	stabilityCmd    = "stability"
+	mergeCmd        = "merge"
+
 	defaultBenchTime    = data.Duration(1 * time.Second)  // 1s
 	defaultBenchTimeout = data.Duration(10 * time.Minute) // 10m
 )
@@ -29,16 +47,31 @@ const (
 // file (in and out) arguments
 var configPath string
 var out string
+var in string
 
 // operation flags
 var dynamic bool
 var trace bool
+var score bool
+var resume bool
+
+// command (instead of operation flags)
+var command string
 
 func parseArguments() {
+	// commands precede the flags (e.g., goabs select -c config.json)
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	flag.StringVar(&configPath, "c", "", "config file")
 	flag.StringVar(&out, "o", "", "output file")
+	flag.StringVar(&in, "i", "", "input file (results of a dynamic run for -s and stability, trace for select)")
 	flag.BoolVar(&dynamic, "d", false, "dynamic coverage")
 	flag.BoolVar(&trace, "t", false, "trace executions of public API")
+	flag.BoolVar(&score, "s", false, "compute ABS from the results of a dynamic run (-i)")
+	flag.BoolVar(&resume, "resume", false, "resume an interrupted dynamic run (-d)")
 	flag.Parse()
 }
 
@@ -65,6 +98,20 @@ func parseConfig() data.Config {
 		panic(fmt.Errorf("No profile dir specified (-profileDir)"))
 	}
 
+	if dynamic {
+		def := config.DynamicConfig.DefaultRegression()
+		for _, f := range config.DynamicConfig.Functions {
+			r := def
+			if f.Regression != nil {
+				r = f.Regression.Or(def)
+			}
+			err := r.Validate()
+			if err != nil {
+				panic(fmt.Errorf("Invalid regression of %s: %v", f, err))
+			}
+		}
+	}
+
 	if config.DynamicConfig.ProfileDir != "" {
 		fi, err := os.Stat(config.DynamicConfig.ProfileDir)
 		if err != nil {
@@ -81,6 +128,16 @@ func parseConfig() data.Config {
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	parseArguments()
+
+	// merge requires no config
+	if command == mergeCmd {
+		err := mergeTraces()
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	c := parseConfig()
 
 	// install project dependencies
@@ -91,8 +148,32 @@ func main() {
 		}
 	}
 
+	switch command {
+	case "":
+	case selectCmd:
+		err := selectFunctions(c)
+		if err != nil {
+			panic(err)
+		}
+		return
+	case coverageCmd:
+		err := benchCoverage(c)
+		if err != nil {
+			panic(err)
+		}
+		return
+	case stabilityCmd:
+		err := stabilityReport(c)
+		if err != nil {
+			panic(err)
+		}
+		return
+	default:
+		panic(fmt.Errorf("Unknown command '%s'", command))
+	}
+
 	if trace {
-		err := count.Functions(c.Project, c.TraceLibrary, out, false)
+		err := traceProjects(c)
 		if err != nil {
 			panic(err)
 		}
@@ -106,16 +187,294 @@ func main() {
 			panic(err)
 		}
 	}
+
+	if score {
+		err := absScore(c)
+		if err != nil {
+			panic(err)
+		}
+	}
 }
 
-func dptc(c data.Config) error {
-	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE, 0777)
+// traceProjects traces the library usage of the projects and ranks the library functions per project and combined
+func traceProjects(c data.Config) error {
+	projects := c.TraceProjects
+	if len(projects) == 0 {
+		projects = []string{c.Project}
+	}
+
+	counts, err := count.Functions(c.GoRoot, projects, c.TraceLibrary, c.DynamicConfig.Workspace, out, c.TraceScope, c.TraceCallers)
+	if err != nil {
+		return err
+	}
+
+	err = counts.Rank().Write(out + rankingSuffix)
+	if err != nil {
+		return fmt.Errorf("Could not write ranking: %v", err)
+	}
+	return nil
+}
+
+// mergeTraces sums the counts of the traces and count folders given as arguments into the trace -o and ranks them
+func mergeTraces() error {
+	if out == "" || flag.NArg() == 0 {
+		return fmt.Errorf("Usage: goabs merge -o TRACE TRACE_OR_COUNTS_DIR...")
+	}
+	counts := count.Counts{}
+	for _, p := range flag.Args() {
+		err := counts.ReadPath(p)
+		if err != nil {
+			return fmt.Errorf("Could not merge traces: %v", err)
+		}
+	}
+	err := counts.Write(out)
+	if err != nil {
+		return fmt.Errorf("Could not write trace: %v", err)
+	}
+	err = counts.Rank().Write(out + rankingSuffix)
+	if err != nil {
+		return fmt.Errorf("Could not write ranking: %v", err)
+	}
+	return nil
+}
+
+func absScore(c data.Config) error {
+	records, err := readResults(c)
+	if err != nil {
+		return err
+	}
+
+	funs := make([]string, 0, len(c.DynamicConfig.Functions))
+	for _, f := range c.DynamicConfig.Functions {
+		funs = append(funs, f.String())
+	}
+
+	report, err := abs.Score(records, funs, c.ABSConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ABS: %d of %d functions detected (%f)\n", report.Detected, report.Total, report.Score)
+
+	return writeJSON(report)
+}
+
+func benchmarks(c data.Config) (data.PackageMap, error) {
+	var benchs data.PackageMap
+	var err error
+	if benchRegex := c.DynamicConfig.BenchmarkRegex; benchRegex != "" {
+		benchs, err = bench.MatchingFunctions(c.Project, benchRegex)
+	} else {
+		benchs, err = bench.Functions(c.Project)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.DynamicConfig.SubBenchmarks {
+		return bench.SubBenchmarks(c.GoRoot, c.Project, benchs)
+	}
+	return benchs, nil
+}
+
+func benchCoverage(c data.Config) error {
+	benchs, err := benchmarks(c)
+	if err != nil {
+		return err
+	}
+
+	allFuns, err := selection.Functions(c.Project, false)
+	if err != nil {
+		return fmt.Errorf("Could not enumerate functions: %v", err)
+	}
+
+	bto := c.DynamicConfig.BenchTimeout
+	if bto == 0 {
+		bto = defaultBenchTimeout
+	}
+
+	m, err := dyncov.Coverage(c.GoRoot, c.Project, benchs, allFuns, bto.ToStdLib(), c.DynamicConfig.KillGrace.ToStdLib())
+	if err != nil {
+		return err
+	}
+
+	// score configured functions or all functions of the project
+	funs := make([]string, 0, len(allFuns))
+	if len(c.DynamicConfig.Functions) > 0 {
+		for _, f := range c.DynamicConfig.Functions {
+			funs = append(funs, f.String())
+		}
+	} else {
+		for _, f := range allFuns {
+			funs = append(funs, f.String())
+		}
+	}
+
+	report := dyncov.Score(m, funs)
+	fmt.Printf("Coverage ABS: %d of %d functions executed by benchmarks (%f)\n", report.Covered, report.Total, report.Score)
+
+	return writeJSON(report)
+}
+
+func selectFunctions(c data.Config) error {
+	sc := c.Selection
+	funs, err := selection.Functions(c.Project, sc.Exported)
+	if err != nil {
+		return fmt.Errorf("Could not enumerate functions: %v", err)
+	}
+	fmt.Printf("%d functions found\n", len(funs))
+
+	if sc.Reachable {
+		funs, err = selection.Reachable(c.Project, funs)
+		if err != nil {
+			return fmt.Errorf("Could not compute reachable functions: %v", err)
+		}
+		fmt.Printf("%d functions reachable from benchmarks\n", len(funs))
+	}
+
+	var counts map[string]int
+	if in != "" {
+		f, err := os.Open(in)
+		if err != nil {
+			return fmt.Errorf("Could not open trace: %v", err)
+		}
+		defer f.Close()
+		counts, err = selection.ReadTraceCounts(f)
+		if err != nil {
+			return fmt.Errorf("Could not read trace: %v", err)
+		}
+	}
+
+	funs, err = selection.Sample(funs, sc.Sampling, sc.N, sc.Seed, counts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d functions selected\n", len(funs))
+
+	return writeJSON(funs)
+}
+
+func stabilityReport(c data.Config) error {
+	records, err := readResults(c)
 	if err != nil {
 		return err
 	}
+
+	report := stability.Analyse(records, c.Stability)
+	fmt.Printf("%d of %d benchmarks unstable\n", report.Unstable, report.Total)
+	return writeJSON(report)
+}
+
+// readResults reads the results of the input file (-i) in the configured output format
+func readResults(c data.Config) ([]bench.Record, error) {
+	if c.DynamicConfig.OutFormat == data.SQLiteFormat {
+		return bench.ReadSQLite(in, c.DynamicConfig.Experiment)
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open results: %v", err)
+	}
 	defer f.Close()
-	out := csv.NewWriter(f)
-	out.Comma = ';'
+
+	if c.DynamicConfig.OutFormat == data.JSONLFormat {
+		return bench.ReadJSONL(f)
+	}
+	return bench.ReadCSV(f)
+}
+
+// writeJSON writes v as indented JSON to the output file (-o) or stdout
+func writeJSON(v interface{}) error {
+	w := os.Stdout
+	if out != "" {
+		of, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer of.Close()
+		w = of
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	return e.Encode(v)
+}
+
+func dptc(c data.Config) error {
+	var findings []preflight.Finding
+	if !c.DynamicConfig.Preflight.Skip {
+		findings = preflight.Check(c.DynamicConfig.Preflight)
+		for _, f := range findings {
+			fmt.Printf("WARNING - measurement hazard %s\n", f)
+		}
+		if len(findings) > 0 && c.DynamicConfig.Preflight.Strict {
+			return fmt.Errorf("Preflight found %d measurement hazards", len(findings))
+		}
+	}
+
+	journal, err := bench.OpenJournal(out+journalSuffix, resume)
+	if err != nil {
+		return fmt.Errorf("Could not open journal: %v", err)
+	}
+	defer journal.Close()
+
+	failures, err := bench.OpenFailureLog(out+failuresSuffix, resume)
+	if err != nil {
+		return fmt.Errorf("Could not open failure log: %v", err)
+	}
+	defer failures.Close()
+
+	if c.DynamicConfig.Isolation == "" {
+		c.DynamicConfig.Isolation = data.OverlayIsolation
+	}
+	order := c.DynamicConfig.Order
+	if order == "" {
+		order = data.DeterministicOrder
+	}
+	if order == data.RMITOrder && c.DynamicConfig.Isolation != data.OverlayIsolation {
+		return fmt.Errorf("Order '%s' requires isolation '%s'", order, data.OverlayIsolation)
+	}
+	if order == data.RMITOrder && c.DynamicConfig.RunDuration > 0 {
+		return fmt.Errorf("Order '%s' does not support 'run_duration'", order)
+	}
+	seed := c.DynamicConfig.OrderSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	orderLog, err := bench.OpenOrderLog(out+orderSuffix, resume, order, seed)
+	if err != nil {
+		return fmt.Errorf("Could not open order log: %v", err)
+	}
+	defer orderLog.Close()
+	seed = orderLog.Seed()
+	fmt.Printf("Execution order '%s' with seed %d\n", order, seed)
+
+	// record the effective configuration
+	c.DynamicConfig.Order = order
+	c.DynamicConfig.OrderSeed = seed
+	err = writeManifest(c, findings, out+manifestSuffix)
+	if err != nil {
+		return fmt.Errorf("Could not write manifest: %v", err)
+	}
+	err = preflight.WriteSession(out+preflightSuffix, preflight.Session{
+		Time:     time.Now(),
+		Resumed:  resume,
+		Skipped:  c.DynamicConfig.Preflight.Skip,
+		Findings: findings,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not write preflight log: %v", err)
+	}
+
+	sink, err := openSink(c, journal)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := sink.Close()
+		if err != nil {
+			fmt.Printf("Could not close results: %v\n", err)
+		}
+	}()
 
 	bto := c.DynamicConfig.BenchTimeout
 	if bto == 0 {
@@ -127,36 +486,56 @@ func dptc(c data.Config) error {
 		bt = defaultBenchTime
 	}
 
-	var benchs data.PackageMap
-	if benchRegex := c.DynamicConfig.BenchmarkRegex; benchRegex != "" {
-		benchs, err = bench.MatchingFunctions(c.Project, benchRegex)
-	} else {
-		benchs, err = bench.Functions(c.Project)
+	var binDir string
+	if c.DynamicConfig.Compile {
+		binDir, err = ioutil.TempDir(c.DynamicConfig.Workspace, binDirPrefix)
+		if err != nil {
+			return fmt.Errorf("Could not create folder for test binaries: %v", err)
+		}
+		defer os.RemoveAll(binDir)
 	}
 
+	benchs, err := benchmarks(c)
 	if err != nil {
 		return err
 	}
 
-	runner, err := bench.NewRunner(
-		c.GoRoot,
-		c.Project,
-		benchs,
-		c.DynamicConfig.WarmupIterations,
-		c.DynamicConfig.MeasurementIterations,
-		bto.ToStdLib(),
-		bt.ToStdLib(),
-		c.DynamicConfig.BenchDuration.ToStdLib(),
-		c.DynamicConfig.RunDuration.ToStdLib(),
-		c.DynamicConfig.BenchMem,
-		c.DynamicConfig.Profile,
-		c.DynamicConfig.ProfileDir,
-		*out,
-	)
+	runner, err := bench.NewRunner(bench.RunnerConfig{
+		GoRoot:                c.GoRoot,
+		ProjectRoot:           c.Project,
+		Benchs:                benchs,
+		WarmupIterations:      c.DynamicConfig.WarmupIterations,
+		MeasurementIterations: c.DynamicConfig.MeasurementIterations,
+		Timeout:               bto.ToStdLib(),
+		KillGrace:             c.DynamicConfig.KillGrace.ToStdLib(),
+		BenchTime:             bt.ToStdLib(),
+		BenchDuration:         c.DynamicConfig.BenchDuration.ToStdLib(),
+		RunDuration:           c.DynamicConfig.RunDuration.ToStdLib(),
+		BenchMem:              c.DynamicConfig.BenchMem,
+		Profile:               c.DynamicConfig.Profile,
+		ProfileDir:            c.DynamicConfig.ProfileDir,
+		Out:                   sink,
+		Journal:               journal,
+		Failures:              failures,
+		Penalty:               c.DynamicConfig.Penalty,
+		Strikes:               c.DynamicConfig.PenaltyStrikes,
+		Order:                 order,
+		OrderLog:              orderLog,
+		Adaptive:              c.DynamicConfig.Adaptive,
+		Warmup:                c.DynamicConfig.Warmup,
+		BinDir:                binDir,
+	})
 	if err != nil {
 		return err
 	}
 
+	if c.DynamicConfig.Workers > 1 || len(c.DynamicConfig.CPUSets) > 0 {
+		runner, err = bench.NewParallelRunner(runner, c.DynamicConfig.Workers, c.DynamicConfig.CPUSets)
+		if err != nil {
+			return err
+		}
+	}
+
 	// check if function/method files can be opened
 	err = checkFiles(c)
 	if err != nil {
@@ -181,14 +560,44 @@ func dptc(c data.Config) error {
 
 	benchCounter := 0
 	start := time.Now()
-	regIntr := regression.NewRelative(c.Project, c.DynamicConfig.Regression)
+	regIntr, err := newIntroducer(c)
+	if err != nil {
+		return err
+	}
+
+	if altered := journal.Altered(); resume && altered != "" && c.DynamicConfig.Isolation == data.GitIsolation {
+		// the interrupted experiment left the regression of altered behind
+		fmt.Printf("Reset regression of interrupted run (%s)\n", altered)
+		err = regIntr.Reset()
+		if err != nil {
+			fmt.Printf("Could not reset regression of interrupted run\n")
+			return err
+		}
+		err = journal.Restore(0, altered)
+		if err != nil {
+			return fmt.Errorf("Could not record reset in journal: %v", err)
+		}
+	}
 
 	for run := 0; run < runs; run++ {
 		fmt.Printf("---------- Run #%d ----------\n", run)
+		if order == data.RMITOrder {
+			test := "interleaved trials"
+			fmt.Printf("--- Run #%d of %s\n", run, test)
+			execBenchs, err, dur := runInterleaved(ctx, c, runner, run)
+			if err != nil {
+				return runTimeoutError(run, test, execBenchs, err, dur)
+			}
+			fmt.Printf("--- Run #%d of %s executed %d which took %dns\n", run, test, execBenchs, dur.Nanoseconds())
+			benchCounter += execBenchs
+			clear()
+			continue
+		}
+
 		// execute baseline run
-		test := "Baseline"
+		test := bench.Baseline
 		fmt.Printf("--- Run #%d of %s\n", run, test)
-		execBenchs, err, dur := bench.TimedRun(ctx, runner, run, test)
+		execBenchs, err, dur := bench.TimedRun(ctx, runner, run, bench.Variant{Test: test})
 		if err != nil {
 			return runTimeoutError(run, test, execBenchs, err, dur)
 		}
@@ -199,20 +608,28 @@ func dptc(c data.Config) error {
 		benchCounter += execBenchs
 		// execute benchmark suite with introduced regressions
 		funs := c.DynamicConfig.Functions
-		if c.DynamicConfig.Rmit {
-			funs = rmitFuncs(c.DynamicConfig.Functions)
+		if c.DynamicConfig.Rmit || order == data.ShuffleOrder {
+			funs = rmitFuncs(c.DynamicConfig.Functions, bench.OrderRand(seed, run))
 			fmt.Println("Using RMIT Methodology")
 		}
 		for _, f := range funs {
 			test = f.String()
 			fmt.Printf("--- Run #%d of %s\n", run, test)
 			// introduce regression into function
-			err := regIntr.Trans(f)
+			err := journal.Alter(run, test)
+			if err != nil {
+				return fmt.Errorf("Could not record regression in journal: %v", err)
+			}
+			err = regIntr.Trans(f)
 			if err != nil {
 				fmt.Printf("Could not introduce regression into function %s\n", test)
 				return err
 			}
-			execBenchs, err, dur := bench.TimedRun(ctx, runner, run, test)
+			v := bench.Variant{
+				Test:      test,
+				BuildArgs: regIntr.BuildArgs(),
+			}
+			execBenchs, err, dur := bench.TimedRun(ctx, runner, run, v)
 			if err != nil {
 				return runTimeoutError(run, test, execBenchs, err, dur)
 			}
@@ -227,13 +644,142 @@ func dptc(c data.Config) error {
 				fmt.Printf("Could not reset regression\n")
 				return err
 			}
+			err = journal.Restore(run, test)
+			if err != nil {
+				return fmt.Errorf("Could not record reset in journal: %v", err)
+			}
 		}
 	}
 	took := time.Since(start)
 	fmt.Printf("\n%d Benchmarks executed in %d runs which took %dns\n", benchCounter, c.DynamicConfig.Runs, took.Nanoseconds())
+	for reason, n := range failures.Counts() {
+		fmt.Printf("%d benchmark executions failed (%s), see %s\n", n, reason, out+failuresSuffix)
+	}
 	return nil
 }
 
+// writeManifest writes the manifest of the experiment; the manifest of a resumed experiment is kept
+func writeManifest(c data.Config, findings []preflight.Finding, path string) error {
+	if resume {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+	m := manifest.Collect(c)
+	m.Preflight = findings
+	return m.Write(path)
+}
+
+// fileSink closes the result file together with the sink
+type fileSink struct {
+	bench.ResultSink
+	f *os.File
+}
+
+func (s fileSink) WriteFailure(f bench.Failure) error {
+	fs, ok := s.ResultSink.(bench.FailureSink)
+	if !ok {
+		return nil
+	}
+	return fs.WriteFailure(f)
+}
+
+func (s fileSink) Close() error {
+	err := s.ResultSink.Close()
+	cerr := s.f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
+
+// openSink opens the result sink.
+// When resuming, results that do not belong to a benchmark execution completed according to the journal are removed,
+// e.g., partial results of interrupted benchmarks (also of other workers) and of failed executions, which are repeated.
+// SQLite results of a resumed experiment keep the experiment of the journal (if not configured).
+func openSink(c data.Config, journal *bench.Journal) (bench.ResultSink, error) {
+	if c.DynamicConfig.OutFormat == data.SQLiteFormat {
+		experiment := c.DynamicConfig.Experiment
+		if experiment == "" {
+			experiment = journal.Experiment()
+		}
+		if experiment == "" {
+			experiment = time.Now().Format(time.RFC3339)
+		}
+		if experiment != journal.Experiment() {
+			err := journal.SetExperiment(experiment)
+			if err != nil {
+				return nil, fmt.Errorf("Could not record experiment in journal: %v", err)
+			}
+		}
+		var completed *bench.Journal
+		if resume {
+			completed = journal
+		}
+		return bench.NewSQLiteSink(out, experiment, completed)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		err := bench.DropIncomplete(out, c.DynamicConfig.OutFormat, journal)
+		if err != nil {
+			return nil, fmt.Errorf("Could not remove incomplete results: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(out, flags, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.DynamicConfig.OutFormat == data.JSONLFormat {
+		return fileSink{ResultSink: bench.NewJSONLSink(f), f: f}, nil
+	}
+	return fileSink{ResultSink: bench.NewCSVSink(f, c.DynamicConfig.BenchMem), f: f}, nil
+}
+
+func newIntroducer(c data.Config) (regression.Introducer, error) {
+	switch c.DynamicConfig.Isolation {
+	case data.GitIsolation:
+		return regression.New(c.Project, c.DynamicConfig.DefaultRegression()), nil
+	default:
+		return regression.NewOverlay(c.Project, c.DynamicConfig.Workspace, c.DynamicConfig.DefaultRegression())
+	}
+}
+
+// runInterleaved executes a run of randomised multiple interleaved trials, i.e., every benchmark is executed for the baseline
+// and all altered functions before the next benchmark. Every function gets its own overlay.
+func runInterleaved(ctx context.Context, c data.Config, runner bench.Runner, run int) (int, error, time.Duration) {
+	ir, ok := runner.(bench.InterleavedRunner)
+	if !ok {
+		return 0, fmt.Errorf("Runner of type %T can not execute interleaved runs", runner), 0
+	}
+
+	vs := []bench.Variant{{Test: bench.Baseline}}
+	for _, f := range c.DynamicConfig.Functions {
+		regIntr, err := regression.NewOverlay(c.Project, c.DynamicConfig.Workspace, c.DynamicConfig.DefaultRegression())
+		if err != nil {
+			return 0, err, 0
+		}
+		defer regIntr.Reset()
+
+		err = regIntr.Trans(f)
+		if err != nil {
+			fmt.Printf("Could not introduce regression into function %s\n", f.String())
+			return 0, err, 0
+		}
+		vs = append(vs, bench.Variant{
+			Test:      f.String(),
+			BuildArgs: regIntr.BuildArgs(),
+		})
+	}
+
+	now := time.Now()
+	execBenchs, err := ir.RunInterleaved(ctx, run, vs)
+	return execBenchs, err, time.Since(now)
+}
+
 func runTimeoutError(run int, test string, execBenchs int, err error, dur time.Duration) error {
 	if errors.Is(err, context.DeadlineExceeded) {
 		fmt.Printf("--- [timed out] Run #%d of %s and executed %d which took %dns\n", run, test, execBenchs, dur.Nanoseconds())
@@ -278,26 +824,11 @@ func clearTmpFolder(path string) func() {
 	}
 }
 
-func rmitFuncs(funcs []data.Function) []data.Function {
-	l := len(funcs)
-	ret := make([]data.Function, l)
-	usedIx := make(map[int]struct{})
-	rnd := rand.NewSource(time.Now().UnixNano())
-	for _, f := range funcs {
-		i := -1
-		used := true
-		for used {
-			i = int(rnd.Int63()) % l
-			_, used = usedIx[i]
-		}
-
-		if i < 0 {
-			panic("i should not be below 0")
-		}
-
-		usedIx[i] = struct{}{}
-
-		ret[i] = f
+// rmitFuncs returns the functions in random order
+func rmitFuncs(funcs []data.Function, rnd *rand.Rand) []data.Function {
+	ret := make([]data.Function, len(funcs))
+	for i, j := range rnd.Perm(len(funcs)) {
+		ret[j] = funcs[i]
 	}
 	return ret
 }
@@ -0,0 +1,219 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/preflight"
+	"github.com/sealuzh/goabs/utils/executil"
+)
+
+const (
+	cpuInfoPath   = "/proc/cpuinfo"
+	loadAvgPath   = "/proc/loadavg"
+	osReleasePath = "/proc/sys/kernel/osrelease"
+	cpuFreqGlob   = "/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"
+
+	cmdGit = "git"
+)
+
+// Manifest describes the machine, toolchain, and project of an experiment
+type Manifest struct {
+	Created    time.Time           `json:"created"`
+	Hostname   string              `json:"hostname"`
+	GOOS       string              `json:"goos"`
+	GOARCH     string              `json:"goarch"`
+	NumCPU     int                 `json:"num_cpu"`
+	GOMAXPROCS string              `json:"gomaxprocs,omitempty"` // GOMAXPROCS of the benchmarks, empty if not set
+	Kernel     string              `json:"kernel,omitempty"`
+	LoadAvg    string              `json:"load_avg,omitempty"`
+	CPU        CPU                 `json:"cpu"`
+	Governors  map[string]int      `json:"governors,omitempty"` // number of CPUs by cpufreq governor
+	GoVersion  string              `json:"go_version"`
+	GoEnv      map[string]string   `json:"go_env"`
+	Project    Project             `json:"project"`
+	Preflight  []preflight.Finding `json:"preflight"` // measurement hazards found before the experiment
+	Config     data.Config         `json:"config"`
+}
+
+// CPU is a summary of /proc/cpuinfo
+type CPU struct {
+	Model   string  `json:"model,omitempty"`
+	Threads int     `json:"threads"`
+	MHz     float64 `json:"mhz,omitempty"` // average current frequency
+	Flags   string  `json:"flags,omitempty"`
+}
+
+// Project is the state of the project under test
+type Project struct {
+	Commit string `json:"commit,omitempty"`
+	Dirty  bool   `json:"dirty"`
+}
+
+// Collect gathers the manifest of an experiment with the effective configuration c.
+// Information that is not available on the host is omitted.
+func Collect(c data.Config) Manifest {
+	m := Manifest{
+		Created: time.Now(),
+		GOOS:    runtime.GOOS,
+		GOARCH:  runtime.GOARCH,
+		NumCPU:  runtime.NumCPU(),
+		Config:  c,
+	}
+
+	host, err := os.Hostname()
+	if err == nil {
+		m.Hostname = host
+	}
+
+	env := executil.ProjectEnv(c.GoRoot, c.Project)
+	m.GOMAXPROCS = lookupEnv(env, "GOMAXPROCS")
+	m.Kernel = readTrimmed(osReleasePath)
+	m.LoadAvg = readTrimmed(loadAvgPath)
+	m.Governors = governors()
+
+	f, err := os.Open(cpuInfoPath)
+	if err == nil {
+		m.CPU = parseCPUInfo(f)
+		f.Close()
+	}
+
+	m.GoVersion, m.GoEnv, err = goToolchain(env, c.Project)
+	if err != nil {
+		fmt.Printf("Could not determine go toolchain: %v\n", err)
+	}
+
+	m.Project, err = gitState(c.Project)
+	if err != nil {
+		fmt.Printf("Could not determine git state of project: %v\n", err)
+	}
+	return m
+}
+
+// Write writes the manifest as indented JSON to path.
+func (m Manifest) Write(path string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0666)
+}
+
+func parseCPUInfo(r io.Reader) CPU {
+	var cpu CPU
+	var mhz float64
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k := strings.TrimSpace(kv[0])
+		v := strings.TrimSpace(kv[1])
+		switch k {
+		case "processor":
+			cpu.Threads++
+		case "model name":
+			cpu.Model = v
+		case "cpu MHz":
+			f, err := strconv.ParseFloat(v, 64)
+			if err == nil {
+				mhz += f
+			}
+		case "flags":
+			cpu.Flags = v
+		}
+	}
+	if cpu.Threads > 0 {
+		cpu.MHz = mhz / float64(cpu.Threads)
+	}
+	return cpu
+}
+
+func governors() map[string]int {
+	paths, err := filepath.Glob(cpuFreqGlob)
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	ret := map[string]int{}
+	for _, p := range paths {
+		if g := readTrimmed(p); g != "" {
+			ret[g]++
+		}
+	}
+	return ret
+}
+
+func goToolchain(env []string, dir string) (string, map[string]string, error) {
+	goCmd := executil.GoCommand(env)
+	c := exec.Command(goCmd, "version")
+	c.Env = env
+	c.Dir = dir
+	out, err := c.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not execute '%s': %v", c.Args, err)
+	}
+	version := strings.TrimSpace(string(out))
+
+	c = exec.Command(goCmd, "env", "-json")
+	c.Env = env
+	c.Dir = dir
+	out, err = c.Output()
+	if err != nil {
+		return version, nil, fmt.Errorf("Could not execute '%s': %v", c.Args, err)
+	}
+	goEnv := map[string]string{}
+	err = json.Unmarshal(out, &goEnv)
+	if err != nil {
+		return version, nil, fmt.Errorf("Could not parse go env: %v", err)
+	}
+	return version, goEnv, nil
+}
+
+func gitState(dir string) (Project, error) {
+	c := exec.Command(cmdGit, "rev-parse", "HEAD")
+	c.Dir = dir
+	out, err := c.Output()
+	if err != nil {
+		return Project{}, fmt.Errorf("Could not execute '%s': %v", c.Args, err)
+	}
+	p := Project{Commit: strings.TrimSpace(string(out))}
+
+	c = exec.Command(cmdGit, "status", "--porcelain")
+	c.Dir = dir
+	out, err = c.Output()
+	if err != nil {
+		return p, fmt.Errorf("Could not execute '%s': %v", c.Args, err)
+	}
+	p.Dirty = len(strings.TrimSpace(string(out))) > 0
+	return p, nil
+}
+
+func readTrimmed(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func lookupEnv(env []string, key string) string {
+	prefix := key + "="
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return e[len(prefix):]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+const cpuInfo = `processor	: 0
+model name	: Intel(R) Xeon(R) CPU E5-2650 v4 @ 2.20GHz
+cpu MHz		: 2200.000
+flags		: fpu vme sse sse2
+
+processor	: 1
+model name	: Intel(R) Xeon(R) CPU E5-2650 v4 @ 2.20GHz
+cpu MHz		: 1800.000
+flags		: fpu vme sse sse2
+`
+
+func TestParseCPUInfo(t *testing.T) {
+	cpu := parseCPUInfo(strings.NewReader(cpuInfo))
+	exp := CPU{
+		Model:   "Intel(R) Xeon(R) CPU E5-2650 v4 @ 2.20GHz",
+		Threads: 2,
+		MHz:     2000,
+		Flags:   "fpu vme sse sse2",
+	}
+	if cpu != exp {
+		t.Errorf("Unexpected CPU summary\nexpected: %+v\nwas:      %+v", exp, cpu)
+	}
+}
@@ -0,0 +1,291 @@
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const (
+	governorGlob    = "sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"
+	noTurboPath     = "sys/devices/system/cpu/intel_pstate/no_turbo"
+	boostPath       = "sys/devices/system/cpu/cpufreq/boost"
+	aslrPath        = "proc/sys/kernel/randomize_va_space"
+	loadAvgPath     = "proc/loadavg"
+	vmStatPath      = "proc/vmstat"
+	procPath        = "proc"
+	performanceGov  = "performance"
+	defaultMaxLoad  = 1.0
+	defaultMaxCPU   = 0.1
+	defaultInterval = data.Duration(time.Second)
+	// clock ticks per second of /proc/[pid]/stat times (USER_HZ, 100 on all common architectures)
+	clockTicks = 100
+)
+
+// Checks of the preflight
+const (
+	GovernorCheck = "governor"
+	TurboCheck    = "turbo"
+	ASLRCheck     = "aslr"
+	LoadCheck     = "load"
+	SwapCheck     = "swap"
+	ProcessCheck  = "processes"
+)
+
+// Finding is a measurement hazard found on the host
+type Finding struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Check, f.Message)
+}
+
+// Session is the preflight of a session of an experiment (the initial run or a resumption)
+type Session struct {
+	Time     time.Time `json:"time"`
+	Resumed  bool      `json:"resumed"`
+	Skipped  bool      `json:"skipped"`
+	Findings []Finding `json:"findings"`
+}
+
+// WriteSession writes s as JSON line to the preflight log at path.
+// If s.Resumed is false, an existing log is truncated, otherwise s is appended.
+func WriteSession(path string, s Session) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if s.Resumed {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	if s.Findings == nil {
+		s.Findings = []Finding{}
+	}
+
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(s)
+	cerr := f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
+
+// Check checks the host for measurement hazards, reading /proc and /sys only.
+// Checks whose files are not available (e.g., on other operating systems) are skipped.
+func Check(c data.Preflight) []Finding {
+	return check("/", c)
+}
+
+func check(root string, c data.Preflight) []Finding {
+	if c.MaxLoad == 0 {
+		c.MaxLoad = defaultMaxLoad
+	}
+	if c.MaxProcessCPU == 0 {
+		c.MaxProcessCPU = defaultMaxCPU
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultInterval
+	}
+
+	fs := []Finding{}
+	fs = append(fs, governors(root)...)
+	fs = append(fs, turbo(root)...)
+	fs = append(fs, aslr(root)...)
+	fs = append(fs, load(root, c.MaxLoad)...)
+
+	// swap activity and CPU utilisation are sampled over the interval
+	swap1, swapOK := swapped(root)
+	procs1 := processTimes(root)
+	time.Sleep(c.Interval.ToStdLib())
+	swap2, _ := swapped(root)
+	procs2 := processTimes(root)
+
+	if swapOK && swap2 > swap1 {
+		fs = append(fs, Finding{
+			Check:   SwapCheck,
+			Message: fmt.Sprintf("%d pages swapped in/out within %s", swap2-swap1, c.Interval.ToStdLib()),
+		})
+	}
+	fs = append(fs, heavyProcesses(procs1, procs2, c.Interval.ToStdLib(), c.MaxProcessCPU)...)
+	return fs
+}
+
+func governors(root string) []Finding {
+	paths, err := filepath.Glob(filepath.Join(root, governorGlob))
+	if err != nil {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, p := range paths {
+		g := readTrimmed(p)
+		if g != "" && g != performanceGov {
+			counts[g]++
+		}
+	}
+
+	fs := []Finding{}
+	for _, g := range sortedKeys(counts) {
+		fs = append(fs, Finding{
+			Check:   GovernorCheck,
+			Message: fmt.Sprintf("%d CPUs use cpufreq governor '%s' instead of '%s'", counts[g], g, performanceGov),
+		})
+	}
+	return fs
+}
+
+func turbo(root string) []Finding {
+	if readTrimmed(filepath.Join(root, noTurboPath)) == "0" {
+		return []Finding{{Check: TurboCheck, Message: "turbo boost enabled (intel_pstate/no_turbo is 0)"}}
+	}
+	if readTrimmed(filepath.Join(root, boostPath)) == "1" {
+		return []Finding{{Check: TurboCheck, Message: "frequency boost enabled (cpufreq/boost is 1)"}}
+	}
+	return nil
+}
+
+func aslr(root string) []Finding {
+	v := readTrimmed(filepath.Join(root, aslrPath))
+	if v == "" || v == "0" {
+		return nil
+	}
+	return []Finding{{Check: ASLRCheck, Message: fmt.Sprintf("address space layout randomisation enabled (randomize_va_space is %s)", v)}}
+}
+
+func load(root string, maxLoad float64) []Finding {
+	fields := strings.Fields(readTrimmed(filepath.Join(root, loadAvgPath)))
+	if len(fields) == 0 {
+		return nil
+	}
+	l, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || l <= maxLoad {
+		return nil
+	}
+	return []Finding{{Check: LoadCheck, Message: fmt.Sprintf("1-minute load average %.2f exceeds %.2f", l, maxLoad)}}
+}
+
+// swapped returns the number of pages swapped in and out since boot
+func swapped(root string) (int64, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(root, vmStatPath))
+	if err != nil {
+		return 0, false
+	}
+	var ret int64
+	found := false
+	for _, l := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) != 2 || (fields[0] != "pswpin" && fields[0] != "pswpout") {
+			continue
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ret += v
+		found = true
+	}
+	return ret, found
+}
+
+// process is the CPU time of a process in clock ticks
+type process struct {
+	name  string
+	ticks int64
+}
+
+// processTimes returns the CPU times of all processes but the current one by pid
+func processTimes(root string) map[int]process {
+	entries, err := ioutil.ReadDir(filepath.Join(root, procPath))
+	if err != nil {
+		return nil
+	}
+	self := os.Getpid()
+	ret := map[int]process{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		p, ok := parseStat(readTrimmed(filepath.Join(root, procPath, e.Name(), "stat")))
+		if ok {
+			ret[pid] = p
+		}
+	}
+	return ret
+}
+
+// parseStat parses the name and CPU time (utime + stime) of /proc/[pid]/stat
+func parseStat(stat string) (process, bool) {
+	// the name is in parentheses and might contain spaces
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return process{}, false
+	}
+	// fields after the name start with state (field 3), utime and stime are fields 14 and 15
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return process{}, false
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return process{}, false
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return process{}, false
+	}
+	return process{name: stat[start+1 : end], ticks: utime + stime}, true
+}
+
+func heavyProcesses(before, after map[int]process, interval time.Duration, maxCPU float64) []Finding {
+	pids := make([]int, 0, len(after))
+	for pid := range after {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	fs := []Finding{}
+	for _, pid := range pids {
+		b, ok := before[pid]
+		if !ok {
+			continue
+		}
+		a := after[pid]
+		cpu := float64(a.ticks-b.ticks) / clockTicks / interval.Seconds()
+		if cpu > maxCPU {
+			fs = append(fs, Finding{
+				Check:   ProcessCheck,
+				Message: fmt.Sprintf("process %s (%d) uses %.0f%% CPU", a.name, pid, cpu*100),
+			})
+		}
+	}
+	return fs
+}
+
+func readTrimmed(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func sortedKeys(m map[string]int) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
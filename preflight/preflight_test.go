@@ -0,0 +1,127 @@
+package preflight
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+func writeFile(t *testing.T, root, path, content string) {
+	p := filepath.Join(root, path)
+	err := os.MkdirAll(filepath.Dir(p), 0777)
+	if err != nil {
+		t.Fatalf("Could not create dir: %v", err)
+	}
+	err = ioutil.WriteFile(p, []byte(content), 0666)
+	if err != nil {
+		t.Fatalf("Could not write file: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFile(t, root, "sys/devices/system/cpu/cpu0/cpufreq/scaling_governor", "performance\n")
+	writeFile(t, root, "sys/devices/system/cpu/cpu1/cpufreq/scaling_governor", "powersave\n")
+	writeFile(t, root, "sys/devices/system/cpu/intel_pstate/no_turbo", "0\n")
+	writeFile(t, root, "proc/sys/kernel/randomize_va_space", "2\n")
+	writeFile(t, root, "proc/loadavg", "3.50 1.00 0.50 2/100 1234\n")
+
+	fs := check(root, data.Preflight{Interval: data.Duration(time.Millisecond)})
+	exp := []string{GovernorCheck, TurboCheck, ASLRCheck, LoadCheck}
+	if len(fs) != len(exp) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(exp), len(fs), fs)
+	}
+	for i, f := range fs {
+		if f.Check != exp[i] {
+			t.Errorf("Expected finding %d to be '%s', was '%s'", i, exp[i], f.Check)
+		}
+	}
+
+	fs = check(root, data.Preflight{MaxLoad: 4, Interval: data.Duration(time.Millisecond)})
+	for _, f := range fs {
+		if f.Check == LoadCheck {
+			t.Errorf("Unexpected load finding with higher threshold: %s", f)
+		}
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	p, ok := parseStat("42 (my proc) S 1 42 42 0 -1 4194304 100 0 0 0 250 50 0 0 20 0 1 0 100 1000 10")
+	if !ok {
+		t.Fatalf("Could not parse stat")
+	}
+	if p.name != "my proc" || p.ticks != 300 {
+		t.Errorf("Unexpected process %+v", p)
+	}
+
+	_, ok = parseStat("42 my proc S 1")
+	if ok {
+		t.Errorf("Expected invalid stat")
+	}
+}
+
+func TestHeavyProcesses(t *testing.T) {
+	before := map[int]process{1: {name: "idle", ticks: 10}, 2: {name: "busy", ticks: 10}}
+	after := map[int]process{1: {name: "idle", ticks: 11}, 2: {name: "busy", ticks: 90}, 3: {name: "new", ticks: 500}}
+
+	fs := heavyProcesses(before, after, time.Second, 0.1)
+	if len(fs) != 1 || fs[0].Message != "process busy (2) uses 80% CPU" {
+		t.Errorf("Unexpected findings %v", fs)
+	}
+}
+
+func TestWriteSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.csv.preflight")
+	sessions := []Session{
+		{Findings: []Finding{{Check: LoadCheck, Message: "old"}}},
+		{Findings: []Finding{{Check: GovernorCheck, Message: "initial"}}},
+		{Resumed: true, Skipped: true},
+		{Resumed: true, Findings: []Finding{{Check: LoadCheck, Message: "resumed"}}},
+	}
+	for _, s := range sessions {
+		err = WriteSession(path, s)
+		if err != nil {
+			t.Fatalf("Could not write session: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Could not open log: %v", err)
+	}
+	defer f.Close()
+	var got []Session
+	d := json.NewDecoder(f)
+	for d.More() {
+		var s Session
+		err = d.Decode(&s)
+		if err != nil {
+			t.Fatalf("Could not decode session: %v", err)
+		}
+		got = append(got, s)
+	}
+
+	// the first experiment is truncated by the second one
+	exp := sessions[1:]
+	exp[1].Findings = []Finding{}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Unexpected sessions\nexpected: %v\nwas:      %v", exp, got)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/sealuzh/goabs/data"
@@ -14,7 +15,10 @@ func TestRmit(t *testing.T) {
 		funcs[i] = fun(i)
 	}
 
-	iFuns := rmitFuncs(funcs)
+	iFuns := rmitFuncs(funcs, rand.New(rand.NewSource(1)))
+	if len(iFuns) != funCount {
+		t.Fatalf("Expected %d functions, got %d", funCount, len(iFuns))
+	}
 
 	for i, f := range iFuns {
 		fmt.Printf("%d - %s\n", i, f.Name)
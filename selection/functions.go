@@ -0,0 +1,114 @@
+package selection
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/astutil"
+	"github.com/sealuzh/goabs/utils/executil"
+	"github.com/sealuzh/goabs/utils/fsutil"
+)
+
+const (
+	goFileSuffix     = ".go"
+	goTestFileSuffix = "_test.go"
+	initFunc         = "init"
+)
+
+// Functions enumerates the functions and methods declared in the non-test files of the project.
+// If exported is set, only exported functions and methods of exported types are returned.
+func Functions(projectRoot string, exported bool) ([]data.Function, error) {
+	funs := []data.Function{}
+	err := filepath.Walk(projectRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if !fsutil.IsValidDir(path) {
+			return filepath.SkipDir
+		}
+
+		if path != projectRoot && executil.IsModuleRoot(path) {
+			// nested modules are not built as part of the project
+			return filepath.SkipDir
+		}
+
+		pkg, err := filepath.Rel(projectRoot, path)
+		if err != nil {
+			return err
+		}
+		if pkg == "." {
+			pkg = ""
+		}
+
+		fileInfos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range fileInfos {
+			fn := fi.Name()
+			if fi.IsDir() || !strings.HasSuffix(fn, goFileSuffix) || strings.HasSuffix(fn, goTestFileSuffix) {
+				continue
+			}
+
+			fileFuns, err := parseFile(filepath.Join(path, fn), pkg, fn, exported)
+			if err != nil {
+				return err
+			}
+			funs = append(funs, fileFuns...)
+		}
+		return nil
+	})
+	return funs, err
+}
+
+func parseFile(path, pkg, fn string, exported bool) ([]data.Function, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	funs := []data.Function{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || fd.Name.Name == initFunc || fd.Name.Name == "_" {
+			// no regression can be injected into external and uncallable functions
+			continue
+		}
+
+		var recv string
+		if fd.Recv != nil {
+			recv, err = astutil.UntypedReceiverType(fd)
+			if err != nil {
+				// receiver not addressable by data.Function (e.g., generic types)
+				continue
+			}
+		}
+
+		fun := data.Function{
+			Pkg:       pkg,
+			File:      fn,
+			Name:      fd.Name.Name,
+			Receiver:  recv,
+			StartLine: fset.Position(fd.Pos()).Line,
+			EndLine:   fset.Position(fd.End()).Line,
+		}
+		if exported && !fun.IsExported() {
+			continue
+		}
+		funs = append(funs, fun)
+	}
+	return funs, nil
+}
@@ -0,0 +1,96 @@
+package selection
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/coverage/static"
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/executil"
+)
+
+const benchFuncPrefix = "Benchmark"
+
+// Reachable returns the functions of funs that are statically reachable from a benchmark of the project.
+// The call graph is built with coverage/static and is therefore an approximation (e.g., no dynamic dispatch).
+func Reachable(projectRoot string, funs []data.Function) ([]data.Function, error) {
+	benchs, err := bench.Functions(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	importPaths := map[string]string{}
+	pkgs := []string{}
+	addPkg := func(pkg string) error {
+		if _, ok := importPaths[pkg]; ok {
+			return nil
+		}
+		ip, err := executil.ImportPath(projectRoot, pkg)
+		if err != nil {
+			return err
+		}
+		importPaths[pkg] = ip
+		pkgs = append(pkgs, ip)
+		return nil
+	}
+
+	for pkg := range benchs {
+		err := addPkg(pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range funs {
+		err := addPkg(f.Pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	finder := static.NewProjectCallSiteFinder(projectRoot, pkgs, false)
+	err = finder.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse call sites: %v", err)
+	}
+	css, err := finder.All()
+	if err != nil {
+		return nil, err
+	}
+
+	// call graph
+	callees := map[string][]string{}
+	roots := []string{}
+	for _, cs := range css {
+		caller := funcKey(cs.Caller.Pkg, cs.Caller.Receiver, cs.Caller.Name)
+		if _, ok := callees[caller]; !ok && cs.Caller.Receiver == "" && strings.HasPrefix(cs.Caller.Name, benchFuncPrefix) {
+			roots = append(roots, caller)
+		}
+		callees[caller] = append(callees[caller], funcKey(cs.Callee.Pkg, cs.Callee.Receiver, cs.Callee.Name))
+	}
+
+	reachable := map[string]struct{}{}
+	for len(roots) > 0 {
+		f := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		for _, c := range callees[f] {
+			if _, ok := reachable[c]; ok {
+				continue
+			}
+			reachable[c] = struct{}{}
+			roots = append(roots, c)
+		}
+	}
+
+	ret := []data.Function{}
+	for _, f := range funs {
+		if _, ok := reachable[funcKey(importPaths[f.Pkg], f.Receiver, f.Name)]; ok {
+			ret = append(ret, f)
+		}
+	}
+	return ret, nil
+}
+
+func funcKey(pkg, recv, name string) string {
+	return data.Function{Pkg: pkg, Receiver: recv, Name: name}.String()
+}
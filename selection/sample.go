@@ -0,0 +1,177 @@
+package selection
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const (
+	traceSep  = ";"
+	traceCols = 3
+	// caller traces contain caller, test, and call kind after the function
+	traceCallerCols = 3
+	tracePathCols   = 2
+)
+
+// Sample selects n functions of funs according to the strategy s. n <= 0 selects all functions (in strategy order).
+// counts are the trace counts (see ReadTraceCounts) and only required for TopSampling.
+func Sample(funs []data.Function, s data.Sampling, n int, seed int64, counts map[string]int) ([]data.Function, error) {
+	if n <= 0 || n > len(funs) {
+		n = len(funs)
+	}
+
+	switch s {
+	case data.RandomSampling, "":
+		return randomSample(funs, n, rand.New(rand.NewSource(seed))), nil
+	case data.StratifiedSampling:
+		return stratifiedSample(funs, n, rand.New(rand.NewSource(seed))), nil
+	case data.TopSampling:
+		if counts == nil {
+			return nil, fmt.Errorf("Sampling '%s' requires trace counts", s)
+		}
+		return topSample(funs, n, counts), nil
+	}
+	return nil, fmt.Errorf("Invalid sampling '%s'", s)
+}
+
+func randomSample(funs []data.Function, n int, r *rand.Rand) []data.Function {
+	ret := make([]data.Function, 0, n)
+	for _, i := range r.Perm(len(funs))[:n] {
+		ret = append(ret, funs[i])
+	}
+	return ret
+}
+
+// stratifiedSample samples from every package proportionally to its number of functions (largest remainder method)
+func stratifiedSample(funs []data.Function, n int, r *rand.Rand) []data.Function {
+	strata := map[string][]data.Function{}
+	pkgs := []string{}
+	for _, f := range funs {
+		if _, ok := strata[f.Pkg]; !ok {
+			pkgs = append(pkgs, f.Pkg)
+		}
+		strata[f.Pkg] = append(strata[f.Pkg], f)
+	}
+	sort.Strings(pkgs)
+
+	type quota struct {
+		pkg       string
+		n         int
+		remainder float64
+	}
+	quotas := make([]quota, 0, len(pkgs))
+	assigned := 0
+	for _, pkg := range pkgs {
+		exact := float64(n) * float64(len(strata[pkg])) / float64(len(funs))
+		q := quota{pkg: pkg, n: int(exact)}
+		q.remainder = exact - float64(q.n)
+		assigned += q.n
+		quotas = append(quotas, q)
+	}
+
+	byRemainder := make([]int, len(quotas))
+	for i := range byRemainder {
+		byRemainder[i] = i
+	}
+	sort.SliceStable(byRemainder, func(i, j int) bool {
+		return quotas[byRemainder[i]].remainder > quotas[byRemainder[j]].remainder
+	})
+	for i := 0; assigned < n; i++ {
+		quotas[byRemainder[i]].n++
+		assigned++
+	}
+
+	ret := make([]data.Function, 0, n)
+	for _, q := range quotas {
+		ret = append(ret, randomSample(strata[q.pkg], q.n, r)...)
+	}
+	return ret
+}
+
+// topSample selects the n most frequently traced functions; functions that were never traced are not selected
+func topSample(funs []data.Function, n int, counts map[string]int) []data.Function {
+	traced := make([]data.Function, 0, len(funs))
+	for _, f := range funs {
+		if counts[traceKey(f)] > 0 {
+			traced = append(traced, f)
+		}
+	}
+
+	sort.SliceStable(traced, func(i, j int) bool {
+		return counts[traceKey(traced[i])] > counts[traceKey(traced[j])]
+	})
+
+	if n > len(traced) {
+		n = len(traced)
+	}
+	return traced[:n]
+}
+
+// ReadTraceCounts counts the calls per function of a trace (goabs -t) of the project.
+// Functions are identified by package, file, receiver, and name.
+func ReadTraceCounts(r io.Reader) (map[string]int, error) {
+	counts := map[string]int{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" {
+			continue
+		}
+
+		// aggregated traces contain the count as last column, caller traces additionally caller, test, and call kind
+		cols := strings.Split(l, traceSep)
+		n := 1
+		switch len(cols) {
+		case traceCols:
+		case traceCols + 1, traceCols + traceCallerCols + 1:
+			var err error
+			n, err = strconv.Atoi(cols[len(cols)-1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid count in trace line '%s'", l)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid trace line '%s'", l)
+		}
+
+		f, err := tracedFunction(cols[traceCols-1])
+		if err != nil {
+			return nil, err
+		}
+		counts[traceKey(f)] += n
+	}
+	return counts, s.Err()
+}
+
+// tracedFunction parses the function path of a trace line (e.g., "index/upsidedown/row.go/(*Row).Key")
+func tracedFunction(p string) (data.Function, error) {
+	elems := strings.Split(p, "/")
+	if len(elems) < tracePathCols {
+		return data.Function{}, fmt.Errorf("Invalid traced function '%s'", p)
+	}
+
+	f := data.Function{
+		Pkg:  strings.Join(elems[:len(elems)-2], "/"),
+		File: elems[len(elems)-2],
+		Name: elems[len(elems)-1],
+	}
+	if strings.HasPrefix(f.Name, "(") {
+		i := strings.Index(f.Name, ").")
+		if i < 0 {
+			return data.Function{}, fmt.Errorf("Invalid traced method '%s'", p)
+		}
+		f.Receiver = f.Name[1:i]
+		f.Name = f.Name[i+2:]
+	}
+	return f, nil
+}
+
+func traceKey(f data.Function) string {
+	return data.Function{Pkg: f.Pkg, File: f.File, Receiver: f.Receiver, Name: f.Name}.String()
+}
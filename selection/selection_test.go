@@ -0,0 +1,230 @@
+package selection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const selectionSrc = `package lib
+
+type Exported struct{}
+
+type unexported struct{}
+
+func init() {}
+
+func Public() {}
+
+func private() {}
+
+func (e *Exported) Method() {}
+
+func (e Exported) method() {}
+
+func (u unexported) Method() {}
+`
+
+func TestFunctions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-selection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, "lib"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "lib", "lib.go"), []byte(selectionSrc), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "lib", "lib_test.go"), []byte("package lib\n\nfunc helper() {}\n"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	funs, err := Functions(dir, false)
+	if err != nil {
+		t.Fatalf("Could not enumerate functions: %v", err)
+	}
+	exp := []string{"lib.{lib.go}.Public", "lib.{lib.go}.private", "lib.{lib.go}.(*Exported).Method", "lib.{lib.go}.(Exported).method", "lib.{lib.go}.(unexported).Method"}
+	if names := names(funs); !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected functions %v, got %v", exp, names)
+	}
+
+	funs, err = Functions(dir, true)
+	if err != nil {
+		t.Fatalf("Could not enumerate functions: %v", err)
+	}
+	exp = []string{"lib.{lib.go}.Public", "lib.{lib.go}.(*Exported).Method"}
+	if names := names(funs); !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected exported functions %v, got %v", exp, names)
+	}
+}
+
+func TestSample(t *testing.T) {
+	funs := []data.Function{}
+	for _, pkg := range []string{"a", "a", "a", "a", "a", "a", "b", "b", "b", "c"} {
+		funs = append(funs, data.Function{Pkg: pkg, File: "f.go", Name: "F" + string(rune('0'+len(funs)))})
+	}
+
+	s1, err := Sample(funs, data.RandomSampling, 4, 42, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := Sample(funs, data.RandomSampling, 4, 42, nil)
+	if len(s1) != 4 || !reflect.DeepEqual(s1, s2) {
+		t.Fatalf("Expected reproducible sample of 4 functions, got %v and %v", s1, s2)
+	}
+
+	s, err := Sample(funs, data.StratifiedSampling, 5, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perPkg := map[string]int{}
+	for _, f := range s {
+		perPkg[f.Pkg]++
+	}
+	// exact quotas: a=3, b=1.5, c=0.5
+	if exp := map[string]int{"a": 3, "b": 2}; !reflect.DeepEqual(perPkg, exp) {
+		t.Fatalf("Expected stratified sample %v, got %v", exp, perPkg)
+	}
+
+	if _, err := Sample(funs, data.TopSampling, 2, 0, nil); err == nil {
+		t.Fatalf("Expected error for top sampling without trace counts")
+	}
+}
+
+func TestTopSample(t *testing.T) {
+	trace := `lib;client;a/f.go/F1
+lib;client;a/f.go/F2
+lib;client;a/f.go/F2
+lib;client;b/f.go/(*T).M
+lib;client;b/f.go/(*T).M
+lib;client;b/f.go/(*T).M
+lib;client;f.go/Root
+`
+	counts, err := ReadTraceCounts(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("Could not read trace: %v", err)
+	}
+
+	funs := []data.Function{
+		{Pkg: "a", File: "f.go", Name: "F1"},
+		{Pkg: "a", File: "f.go", Name: "F2"},
+		{Pkg: "a", File: "f.go", Name: "F3"},
+		{Pkg: "b", File: "f.go", Name: "M", Receiver: "*T"},
+		{Pkg: "", File: "f.go", Name: "Root"},
+	}
+	s, err := Sample(funs, data.TopSampling, 3, 0, counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"b.{f.go}.(*T).M", "a.{f.go}.F2", "a.{f.go}.F1"}
+	if names := names(s); !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected top functions %v, got %v", exp, names)
+	}
+
+	s, _ = Sample(funs, data.TopSampling, 0, 0, counts)
+	if len(s) != 4 {
+		t.Fatalf("Expected all 4 traced functions, got %v", names(s))
+	}
+}
+
+func TestReadAggregatedTraceCounts(t *testing.T) {
+	trace := `lib;client;a/f.go/F1;3
+lib;client2;a/f.go/F1;2
+lib;client;b/f.go/(*T).M
+lib;client;b/f.go/(*T).M;example.com/client.TestM;example.com/client.TestM;direct;2
+`
+	counts, err := ReadTraceCounts(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("Could not read trace: %v", err)
+	}
+	exp := map[string]int{"a.{f.go}.F1": 5, "b.{f.go}.(*T).M": 3}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("Expected counts %v, got %v", exp, counts)
+	}
+
+	if _, err := ReadTraceCounts(strings.NewReader("lib;client;a/f.go/F1;x\n")); err == nil {
+		t.Fatalf("Expected error for invalid count")
+	}
+}
+
+func TestReachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goabs-selection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod": "module example.com/reach\n",
+		"lib/lib.go": `package lib
+
+type T struct{}
+
+func (t *T) M() { helper() }
+
+func helper() {}
+
+func Unused() {}
+`,
+		"lib/lib_test.go": `package lib
+
+import "testing"
+
+func BenchmarkM(b *testing.B) {
+	t := &T{}
+	for i := 0; i < b.N; i++ {
+		t.M()
+	}
+}
+`,
+		"api/api.go": `package api
+
+import "example.com/reach/lib"
+
+func Call() { lib.Unused() }
+`,
+	}
+	for p, src := range files {
+		p = filepath.Join(dir, p)
+		err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(p, []byte(src), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	funs, err := Functions(dir, false)
+	if err != nil {
+		t.Fatalf("Could not enumerate functions: %v", err)
+	}
+	reachable, err := Reachable(dir, funs)
+	if err != nil {
+		t.Fatalf("Could not compute reachable functions: %v", err)
+	}
+	exp := []string{"lib.{lib.go}.(*T).M", "lib.{lib.go}.helper"}
+	if names := names(reachable); !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Expected reachable functions %v, got %v", exp, names)
+	}
+}
+
+func names(funs []data.Function) []string {
+	ret := make([]string, 0, len(funs))
+	for _, f := range funs {
+		ret = append(ret, f.String())
+	}
+	return ret
+}
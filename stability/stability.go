@@ -0,0 +1,170 @@
+package stability
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/statsutil"
+)
+
+const (
+	defaultMetric     = "ns/op"
+	defaultMaxCV      = 0.1
+	defaultMaxCIWidth = 0.1
+	defaultConfidence = 0.95
+	defaultResamples  = 1000
+)
+
+// Report quantifies the variability of every benchmark series (test and benchmark) of an experiment.
+type Report struct {
+	Metric     string               `json:"metric"`
+	MaxCV      float64              `json:"max_cv"`
+	MaxCIWidth float64              `json:"max_ci_width"`
+	Confidence float64              `json:"confidence"`
+	Benchmarks []BenchmarkStability `json:"benchmarks"`
+	Unstable   int                  `json:"unstable"`
+	Total      int                  `json:"total"`
+}
+
+type BenchmarkStability struct {
+	Test        string  `json:"test"`
+	Benchmark   string  `json:"benchmark"`
+	Runs        int     `json:"runs"`
+	N           int     `json:"n"`
+	Mean        float64 `json:"mean"`
+	CV          float64 `json:"cv"`       // coefficient of variation of all measurements
+	CIWidth     float64 `json:"ci_width"` // width of the bootstrap confidence interval of the mean, relative to the mean
+	WithinRuns  float64 `json:"within_runs_variance"`
+	BetweenRuns float64 `json:"between_runs_variance"`
+	Unstable    bool    `json:"unstable"`
+}
+
+type seriesKey struct {
+	test      string
+	benchmark string
+}
+
+// Analyse computes the variability of every benchmark series of the records.
+// A benchmark is unstable if its coefficient of variation exceeds max_cv or its relative confidence interval width exceeds max_ci_width.
+func Analyse(records []bench.Record, c data.Stability) Report {
+	c = withDefaults(c)
+
+	series := map[seriesKey]map[int][]float64{}
+	for _, r := range records {
+		v, ok := r.Metrics[c.Metric]
+		if !ok || r.Warmup {
+			continue
+		}
+		k := seriesKey{test: r.Test, benchmark: r.Benchmark}
+		runs, ok := series[k]
+		if !ok {
+			runs = map[int][]float64{}
+			series[k] = runs
+		}
+		runs[r.Run] = append(runs[r.Run], v)
+	}
+
+	keys := make([]seriesKey, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].test != keys[j].test {
+			return keys[i].test < keys[j].test
+		}
+		return keys[i].benchmark < keys[j].benchmark
+	})
+
+	report := Report{
+		Metric:     c.Metric,
+		MaxCV:      c.MaxCV,
+		MaxCIWidth: c.MaxCIWidth,
+		Confidence: c.Confidence,
+		Benchmarks: make([]BenchmarkStability, 0, len(keys)),
+		Total:      len(keys),
+	}
+	r := rand.New(rand.NewSource(c.Seed))
+	for _, k := range keys {
+		bs := analyseSeries(series[k], c, r)
+		bs.Test = k.test
+		bs.Benchmark = k.benchmark
+		if bs.Unstable {
+			report.Unstable++
+		}
+		report.Benchmarks = append(report.Benchmarks, bs)
+	}
+	return report
+}
+
+func analyseSeries(runs map[int][]float64, c data.Stability, r *rand.Rand) BenchmarkStability {
+	ids := make([]int, 0, len(runs))
+	for id := range runs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	all := []float64{}
+	groups := make([][]float64, 0, len(ids))
+	runMeans := make([]float64, 0, len(ids))
+	runVars := make([]float64, 0, len(ids))
+	for _, id := range ids {
+		ms := runs[id]
+		all = append(all, ms...)
+		groups = append(groups, ms)
+		runMeans = append(runMeans, statsutil.Mean(ms))
+		if v := statsutil.Variance(ms); !math.IsNaN(v) {
+			runVars = append(runVars, v)
+		}
+	}
+
+	mean := statsutil.Mean(all)
+	lo, hi := statsutil.BootstrapMeanCI(groups, c.Confidence, c.Resamples, r)
+
+	bs := BenchmarkStability{
+		Runs:        len(ids),
+		N:           len(all),
+		Mean:        mean,
+		CV:          statsutil.StdDev(all) / mean,
+		CIWidth:     (hi - lo) / mean,
+		WithinRuns:  statsutil.Mean(runVars),
+		BetweenRuns: statsutil.Variance(runMeans),
+	}
+	// too few measurements are unstable as well
+	bs.Unstable = !(bs.CV <= c.MaxCV) || !(bs.CIWidth <= c.MaxCIWidth)
+
+	// JSON does not support NaN: undefined statistics (too few measurements or runs) are reported as 0
+	bs.CV = defined(bs.CV)
+	bs.CIWidth = defined(bs.CIWidth)
+	bs.WithinRuns = defined(bs.WithinRuns)
+	bs.BetweenRuns = defined(bs.BetweenRuns)
+	return bs
+}
+
+func defined(x float64) float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0
+	}
+	return x
+}
+
+func withDefaults(c data.Stability) data.Stability {
+	if c.Metric == "" {
+		c.Metric = defaultMetric
+	}
+	if c.MaxCV == 0 {
+		c.MaxCV = defaultMaxCV
+	}
+	if c.MaxCIWidth == 0 {
+		c.MaxCIWidth = defaultMaxCIWidth
+	}
+	if c.Confidence == 0 {
+		c.Confidence = defaultConfidence
+	}
+	if c.Resamples == 0 {
+		c.Resamples = defaultResamples
+	}
+	return c
+}
@@ -0,0 +1,56 @@
+package stability
+
+import (
+	"testing"
+
+	"github.com/sealuzh/goabs/bench"
+	"github.com/sealuzh/goabs/data"
+)
+
+func records(test, benchmark string, runs ...[]float64) []bench.Record {
+	ret := []bench.Record{}
+	for run, ms := range runs {
+		for _, m := range ms {
+			ret = append(ret, bench.Record{
+				Run:       run,
+				Test:      test,
+				Benchmark: benchmark,
+				Metrics:   map[string]float64{"ns/op": m},
+			})
+		}
+	}
+	return ret
+}
+
+func TestAnalyse(t *testing.T) {
+	rs := records(bench.Baseline, "stable", []float64{100, 101, 99}, []float64{100, 100, 100})
+	rs = append(rs, records(bench.Baseline, "noisy", []float64{50, 150, 100}, []float64{200, 20, 80})...)
+	rs = append(rs, records(bench.Baseline, "single", []float64{100})...)
+
+	report := Analyse(rs, data.Stability{Seed: 1})
+	if report.Total != 3 || report.Unstable != 2 {
+		t.Fatalf("Expected 2 of 3 unstable benchmarks, got %d of %d", report.Unstable, report.Total)
+	}
+
+	byName := map[string]BenchmarkStability{}
+	for _, b := range report.Benchmarks {
+		byName[b.Benchmark] = b
+	}
+
+	s := byName["stable"]
+	if s.Unstable || s.Runs != 2 || s.N != 6 || s.Mean != 100 {
+		t.Fatalf("Unexpected stable benchmark: %+v", s)
+	}
+	if s.BetweenRuns != 0 || s.WithinRuns != 0.5 {
+		t.Fatalf("Unexpected variances of stable benchmark: %+v", s)
+	}
+
+	if n := byName["noisy"]; !n.Unstable || n.CV <= report.MaxCV {
+		t.Fatalf("Expected noisy benchmark to be unstable: %+v", n)
+	}
+
+	// undefined statistics are unstable but reported as 0
+	if s := byName["single"]; !s.Unstable || s.CV != 0 || s.BetweenRuns != 0 {
+		t.Fatalf("Unexpected single-measurement benchmark: %+v", s)
+	}
+}
@@ -0,0 +1,96 @@
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sealuzh/goabs/utils/executil"
+)
+
+const (
+	goSumFile       = "go.sum"
+	replaceDir      = "replace"
+	cmdArgsModFile  = "-modfile=%s"
+	replaceBlockEnd = ")"
+)
+
+// substitute returns the folder of the library that project builds with and the build arguments that make it use the library.
+// Vendored copies of the library are replaced by the overlay; module projects that do not vendor the library
+// get a copy of their go.mod (-modfile) that replaces the library module with traceLibrary.
+func (in *Instrumentation) substitute(project string) (string, []string, error) {
+	root, ok := executil.ModuleRoot(project)
+	if !ok {
+		// GOPATH projects prefer their vendored copy of the library
+		vendored := filepath.Join(project, vendorFolder, filepath.FromSlash(in.importPath))
+		if isDir(vendored) {
+			return vendored, nil, nil
+		}
+		return in.library, nil, nil
+	}
+
+	if in.module == "" {
+		return "", nil, fmt.Errorf("Trace library %s is not a module, hence module project %s can not use it", in.library, project)
+	}
+	if executil.IsVendored(root) {
+		vendored := filepath.Join(root, vendorFolder, filepath.FromSlash(in.importPath))
+		if !isDir(vendored) {
+			return "", nil, fmt.Errorf("Project %s does not vendor %s", project, in.importPath)
+		}
+		return vendored, nil, nil
+	}
+
+	goMod, err := ioutil.ReadFile(filepath.Join(root, executil.GoModFile))
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not read go.mod of project %s: %v", project, err)
+	}
+	modFile, err := in.write(executil.GoModFile, replaceModule(goMod, in.module, in.moduleRoot))
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not write go.mod: %v", err)
+	}
+
+	// go uses the go.sum next to the go.mod
+	goSum, err := ioutil.ReadFile(filepath.Join(root, goSumFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("Could not read go.sum of project %s: %v", project, err)
+	}
+	err = ioutil.WriteFile(strings.TrimSuffix(modFile, ".mod")+".sum", goSum, os.ModePerm)
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not write go.sum: %v", err)
+	}
+	return in.library, []string{fmt.Sprintf(cmdArgsModFile, modFile)}, nil
+}
+
+// replaceModule returns goMod with all replace directives of module removed and a replace directive of module with dir added
+func replaceModule(goMod []byte, module, dir string) []byte {
+	var buf bytes.Buffer
+	inBlock := false
+	s := bufio.NewScanner(bytes.NewReader(goMod))
+	for s.Scan() {
+		l := s.Text()
+		fields := strings.Fields(l)
+		switch {
+		case inBlock && len(fields) > 0 && fields[0] == replaceBlockEnd:
+			inBlock = false
+		case len(fields) == 2 && fields[0] == replaceDir && fields[1] == "(":
+			inBlock = true
+		case inBlock && len(fields) > 0 && fields[0] == module:
+			continue
+		case len(fields) > 1 && fields[0] == replaceDir && fields[1] == module:
+			continue
+		}
+		buf.WriteString(l)
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(&buf, "%s %s => %s\n", replaceDir, module, dir)
+	return buf.Bytes()
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
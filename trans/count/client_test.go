@@ -0,0 +1,30 @@
+package count
+
+import "testing"
+
+func TestReplaceModule(t *testing.T) {
+	goMod := `module example.com/cli
+
+require example.com/lib v1.0.0
+
+replace example.com/lib => ../old
+
+replace (
+	example.com/lib v1.0.0 => ../older
+	example.com/other => ../other
+)
+`
+	exp := `module example.com/cli
+
+require example.com/lib v1.0.0
+
+
+replace (
+	example.com/other => ../other
+)
+replace example.com/lib => /tmp/lib
+`
+	if was := string(replaceModule([]byte(goMod), "example.com/lib", "/tmp/lib")); was != exp {
+		t.Fatalf("Unexpected go.mod\nexpected:\n%s\nwas:\n%s", exp, was)
+	}
+}
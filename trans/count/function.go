@@ -2,6 +2,7 @@ package count
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -10,9 +11,12 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/sealuzh/goabs/data"
 	"github.com/sealuzh/goabs/utils/astutil"
 	"github.com/sealuzh/goabs/utils/executil"
 )
@@ -26,50 +30,224 @@ const (
 	vendorFolder    = "vendor"
 	oldVendorFolder = "_vendor"
 	srcFolder       = "src"
+	initFunc        = "init"
+
+	overlayFolderPrefix = "goabs-trace-"
+	overlayFile         = "overlay.json"
+
+	cmdArgsTest    = "test"
+	cmdArgsOverlay = "-overlay=%s"
+	// vet can not enter the writer package, which only exists in the overlay
+	cmdArgsNoVet = "-vet=off"
+	// cached test results would not write traces
+	cmdArgsNoCache = "-count=1"
+	allPkgs        = "./..."
+
+	buildFailedMsg = "[build failed]"
+	setupFailedMsg = "[setup failed]"
 )
 
-func Functions(project, traceLibrary, out string, execTests bool) error {
-	// create writer
-	writerPkgName, err := createWriter(traceLibrary, out)
+// Functions traces the calls of the exported functions and methods of traceLibrary by the unit tests of projects
+// and writes their counts to the trace out, which is overwritten (traces of several invocations are summed with Merge).
+// The library is instrumented once in an overlay in workspace (requires Go 1.16 or newer), which is removed afterwards;
+// the library itself is never modified, hence the same library can be traced any number of times.
+// Projects whose tests can not be built or executed are reported and skipped.
+// scope defines the traced functions, with callers the calls are counted per caller (see Instrument).
+func Functions(goRoot string, projects []string, traceLibrary, workspace, out string, scope data.TraceScope, callers bool) (Counts, error) {
+	in, err := Instrument(traceLibrary, workspace, scope, callers)
 	if err != nil {
-		fmt.Println("Could not create trace writer")
-		return err
+		return nil, err
 	}
+	defer in.Close()
 
-	projectName := filepath.Base(project)
-	libraryName := filepath.Base(traceLibrary)
+	c := Counts{}
+	traced := 0
+	for _, p := range projects {
+		err := in.Exec(goRoot, p, c)
+		if err != nil {
+			fmt.Printf("Could not trace project %s: %v\n", p, err)
+			continue
+		}
+		traced++
 
-	// transform traceLibrary
-	err = transformLibrary(traceLibrary, writerPkgName, projectName, libraryName)
+		// the counts of traced projects are kept if goabs is interrupted
+		err = c.Write(out)
+		if err != nil {
+			return nil, fmt.Errorf("Could not write trace: %v", err)
+		}
+	}
+	fmt.Printf("Traced %d of %d projects\n", traced, len(projects))
+	if traced == 0 {
+		err = c.Write(out)
+		if err != nil {
+			return nil, fmt.Errorf("Could not write trace: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Instrumentation is an instrumented trace library stored as an overlay of go build
+type Instrumentation struct {
+	library    string
+	importPath string
+	module     string // module path of the library, empty for GOPATH libraries
+	moduleRoot string
+	scope      data.TraceScope
+	callers    bool
+	dir        string
+	files      map[string]string // instrumented files by path relative to the library
+	n          int               // files written to dir
+	names      []string          // traced functions by counter
+}
+
+// Instrument writes an instrumented copy of all non-test files of traceLibrary to an overlay in workspace (default: system temp folder).
+// Every function within scope (default: exported functions and methods) counts its calls;
+// the counts are flushed periodically and at exit of the tests (see Exec).
+// With callers, the calls are counted per nearest caller outside the library (e.g., the client function), running test,
+// and whether the function was called directly by the caller or transitively by another library function.
+func Instrument(traceLibrary, workspace string, scope data.TraceScope, callers bool) (*Instrumentation, error) {
+	traceLibrary, err := filepath.Abs(traceLibrary)
 	if err != nil {
-		fmt.Println("Could not transform library")
-		return err
+		return nil, err
 	}
 
-	if execTests {
-		// execute unit tests
-		err = os.Chdir(project)
+	// libraries instrumented in place by earlier versions would be traced twice
+	if _, err := os.Stat(filepath.Join(traceLibrary, pkgName)); err == nil {
+		return nil, fmt.Errorf("Trace library %s was instrumented in place, remove folder %s and restore its files first", traceLibrary, pkgName)
+	}
+
+	in := &Instrumentation{
+		library:    traceLibrary,
+		importPath: strings.TrimSuffix(basePkg(traceLibrary), "/"),
+		scope:      scope,
+		callers:    callers,
+		files:      map[string]string{},
+	}
+	if root, ok := executil.ModuleRoot(traceLibrary); ok {
+		in.moduleRoot = root
+		in.module, err = executil.ModulePath(root)
 		if err != nil {
-			fmt.Printf("Could not change directory to %s\n", project)
-			return err
+			return nil, fmt.Errorf("Could not resolve module path of %s: %v", traceLibrary, err)
 		}
+	}
 
-		c := exec.Command("go", "test", "./...")
-		c.Env = executil.Env("", executil.GoPath(project))
-		res, err := c.CombinedOutput()
-		if err != nil {
-			fmt.Printf("Error while executing go test command: %v\n", err)
-			if res != nil {
-				fmt.Println(res)
+	in.dir, err = ioutil.TempDir(workspace, overlayFolderPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create overlay folder in '%s': %v", workspace, err)
+	}
+
+	err = os.Mkdir(in.countsDir(), os.ModePerm)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("Could not create counts folder: %v", err)
+	}
+
+	// transform traceLibrary
+	err = in.transformLibrary(in.writerPkg())
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("Could not transform library: %v", err)
+	}
+
+	// create writer with a counter per transformed function
+	err = in.createWriter(filepath.Base(traceLibrary))
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("Could not create trace writer: %v", err)
+	}
+	return in, nil
+}
+
+// Exec executes all unit tests of project with the instrumented library and adds the counts to counts.
+// The project uses the instrumented library in place of its vendored copy, or in place of the module it requires (with a replace directive).
+// The counts are flushed at exit of every test package (see addTestMains) and periodically.
+// Failing tests are reported but do not fail the execution, as the counts of the passing tests are still valid;
+// tests that can not be built fail the execution. Projects are named by their import path (see projectName).
+func (in *Instrumentation) Exec(goRoot, project string, counts Counts) error {
+	project, err := filepath.Abs(project)
+	if err != nil {
+		return err
+	}
+
+	root, args, err := in.substitute(project)
+	if err != nil {
+		return err
+	}
+
+	replace := make(map[string]string, len(in.files))
+	for rel, altered := range in.files {
+		replace[filepath.Join(root, rel)] = altered
+	}
+	err = in.addTestMains(project, replace)
+	if err != nil {
+		return fmt.Errorf("Could not add TestMain functions: %v", err)
+	}
+	overlayPath, err := in.writeOverlay(replace)
+	if err != nil {
+		return fmt.Errorf("Could not write overlay: %v", err)
+	}
+
+	env := executil.ProjectEnv(goRoot, project)
+	env = executil.SetEnv(env, projectEnvVar, projectName(project))
+	args = append([]string{cmdArgsTest, cmdArgsNoCache, fmt.Sprintf(cmdArgsOverlay, overlayPath), cmdArgsNoVet}, args...)
+	c := exec.Command(executil.GoCommand(env), append(args, allPkgs)...)
+	c.Dir = project
+	c.Env = env
+
+	fmt.Printf("Execute unit tests of %s\n", project)
+	res, err := c.CombinedOutput()
+	fmt.Println(string(res))
+	if _, ok := err.(*exec.ExitError); ok {
+		out := string(res)
+		if strings.Contains(out, buildFailedMsg) || strings.Contains(out, setupFailedMsg) {
+			// the counts of the packages that were built are incomplete
+			cerr := in.clearCounts()
+			if cerr != nil {
+				return cerr
 			}
-			return err
+			return fmt.Errorf("Could not build unit tests of %s", project)
 		}
-		fmt.Println(res)
+		fmt.Printf("WARNING - unit tests of %s failed: %v\n", project, err)
+	} else if err != nil {
+		return fmt.Errorf("Could not execute '%s': %v", c.Args, err)
+	}
+
+	// counts of a process are rewritten with every flush, hence they are read after all processes exited
+	err = counts.ReadPath(in.countsDir())
+	if err != nil {
+		return err
+	}
+	return in.clearCounts()
+}
+
+// projectName returns the name of project in the trace, i.e., its import path (e.g., module path) or its path otherwise
+func projectName(project string) string {
+	ip, err := executil.ImportPath(project, "")
+	if err != nil {
+		return project
+	}
+	return ip
+}
+
+// Close removes the overlay.
+func (in *Instrumentation) Close() error {
+	err := os.RemoveAll(in.dir)
+	if err != nil {
+		return fmt.Errorf("Could not remove overlay folder %s: %v", in.dir, err)
 	}
 	return nil
 }
 
 func basePkg(traceLibrary string) string {
+	// modules are imported relative to their module path
+	if executil.IsModule(traceLibrary) {
+		ip, err := executil.ImportPath(traceLibrary, "")
+		if err == nil {
+			return ip + "/"
+		}
+		fmt.Printf("Could not resolve import path of %s: %v\n", traceLibrary, err)
+	}
+
 	pathArr := strings.Split(traceLibrary, string(filepath.Separator))
 
 	// check whether library is in GOPATH or in vender folder
@@ -93,70 +271,47 @@ func basePkg(traceLibrary string) string {
 	return buf.String()
 }
 
-func createWriter(traceLibrary, out string) (string, error) {
-	pkgPath := filepath.Join(traceLibrary, pkgName)
-	err := os.Mkdir(pkgPath, os.ModePerm)
-	if err != nil {
-		fmt.Printf("Could not create trace writer directory")
-		return "", err
-	}
-
-	basePkgName := basePkg(traceLibrary)
+// overlay is the JSON format of go build's -overlay flag
+type overlay struct {
+	Replace map[string]string
+}
 
-	wd, err := os.Getwd()
+// writeOverlay writes the overlay replace to a new file and returns its path
+func (in *Instrumentation) writeOverlay(replace map[string]string) (string, error) {
+	b, err := json.Marshal(overlay{Replace: replace})
 	if err != nil {
-		fmt.Println("Could not retrieve working directory")
 		return "", err
 	}
+	return in.write(overlayFile, b)
+}
 
-	writerSrc := `
-	package %s
-
-	import (
-		"os"
-		"fmt"
-		"sync"
-	)
-
-	var %s = createTraceWriter("%s")
+// write writes src to a new file of the overlay folder named after name and returns its path
+func (in *Instrumentation) write(name string, src []byte) (string, error) {
+	path := filepath.Join(in.dir, fmt.Sprintf("%d_%s", in.n, name))
+	in.n++
+	return path, ioutil.WriteFile(path, src, os.ModePerm)
+}
 
-	func createTraceWriter(outPath string) *traceWriter {
-		f, err := os.OpenFile(outPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_SYNC, os.ModePerm)
-		if err != nil {
-			panic(fmt.Sprint("Could not create PTC trace writer file: " + err.Error()))
-		}
-		return &traceWriter{
-			f: f,
-		}
+// save writes src to the overlay as file rel (relative to the library)
+func (in *Instrumentation) save(rel string, src []byte) error {
+	altered, err := in.write(filepath.Base(rel), src)
+	if err != nil {
+		return err
 	}
+	in.files[rel] = altered
+	return nil
+}
 
-	type traceWriter struct{
-		f *os.File
-		l sync.Mutex
-	}
-	
-	func (tw *traceWriter) Write(b []byte) {
-		tw.l.Lock()
-		defer tw.l.Unlock()
-		_, err := tw.f.Write(b)
+func (in *Instrumentation) transformLibrary(writerPkgName string) error {
+	fmt.Printf("Start transforming library %s\n", in.library)
+	err := filepath.Walk(in.library, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			panic("Couldn't write to trace file: " + err.Error())
+			return err
 		}
-	}
-	`
-	err = ioutil.WriteFile(filepath.Join(pkgPath, fileName),
-		[]byte(fmt.Sprintf(writerSrc, pkgName, writerVar, filepath.Join(wd, out))),
-		os.ModePerm)
-	return filepath.Join(basePkgName, pkgName), err
-}
-
-func transformLibrary(path, writerPkgName, projectName, libraryName string) error {
-	fmt.Printf("Start transforming library %s\n", path)
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if info.IsDir() {
 			pathElems := strings.Split(path, string(filepath.Separator))
 			el := pathElems[len(pathElems)-1]
-			if strings.HasPrefix(el, ".") ||
+			if path != in.library && strings.HasPrefix(el, ".") ||
 				el == pkgName ||
 				el == godepsFolder ||
 				el == vendorFolder ||
@@ -182,7 +337,7 @@ func transformLibrary(path, writerPkgName, projectName, libraryName string) erro
 		}
 		fmt.Printf("  transform file %s\n", path)
 
-		err = transformFile(path, f, fset, writerPkgName, projectName, libraryName)
+		err = in.transformFile(path, f, fset, writerPkgName)
 		if err != nil {
 			fmt.Printf("Could not transform file %s\n", path)
 			return err
@@ -193,13 +348,22 @@ func transformLibrary(path, writerPkgName, projectName, libraryName string) erro
 	return err
 }
 
-func transformFile(path string, f *ast.File, fset *token.FileSet, writerPkgName, projectName, libraryName string) error {
-	v := publicFuncCountVisitor{
-		writerPkgName: writerPkgName,
-		projectName:   projectName,
-		libraryName:   libraryName,
-		relPath:       relPath(path, libraryName),
-		transformed:   &transformed{},
+func (in *Instrumentation) transformFile(path string, f *ast.File, fset *token.FileSet, writerPkgName string) error {
+	rel, err := filepath.Rel(in.library, path)
+	if err != nil {
+		return err
+	}
+	pkg := filepath.ToSlash(filepath.Dir(rel))
+	if pkg == "." {
+		pkg = ""
+	}
+
+	v := funcCountVisitor{
+		in:          in,
+		fset:        fset,
+		pkg:         pkg,
+		file:        filepath.Base(path),
+		transformed: &transformed{},
 	}
 
 	ast.Walk(v, f)
@@ -213,15 +377,16 @@ func transformFile(path string, f *ast.File, fset *token.FileSet, writerPkgName,
 			panic(fmt.Sprintf("pkgName '%s' != pkgNameRet '%s'", pkgName, pkgNameRet))
 		}
 
-		// write transformed file back to file
-		file, err := os.Create(path)
+		// write transformed file to the overlay
+		var buf bytes.Buffer
+		err := printer.Fprint(&buf, fset, f)
 		if err != nil {
-			fmt.Printf("Can not open file for rewriting it: %s\n", path)
+			fmt.Printf("Can not print transformed src of file: %s\n", path)
 			return err
 		}
-		err = printer.Fprint(file, fset, f)
+		err = in.save(rel, buf.Bytes())
 		if err != nil {
-			fmt.Printf("Can not write transformed src back to file: %s\n", path)
+			fmt.Printf("Can not write transformed src to overlay: %s\n", path)
 			return err
 		}
 	}
@@ -229,90 +394,111 @@ func transformFile(path string, f *ast.File, fset *token.FileSet, writerPkgName,
 	return nil
 }
 
-func relPath(path, libraryName string) string {
-	pathArr := strings.Split(path, string(filepath.Separator))
-	inLib := false
-	var buf bytes.Buffer
-	for _, el := range pathArr {
-		if el == libraryName {
-			inLib = true
-			continue
-		}
-		if inLib {
-			buf.WriteString(el)
-			buf.WriteString("/")
-		}
+// traceName is the name of fun in traces (e.g., "index/upsidedown/row.go/(*Row).Key")
+func traceName(fun data.Function) string {
+	name := fun.Name
+	if fun.Receiver != "" {
+		name = fmt.Sprintf("(%s).%s", fun.Receiver, fun.Name)
 	}
-	return buf.String()
+	return path.Join(fun.Pkg, fun.File, name)
 }
 
 type transformed struct {
 	v bool
 }
 
-type publicFuncCountVisitor struct {
-	writerPkgName string
-	projectName   string
-	libraryName   string
-	relPath       string
-	transformed   *transformed
+// funcCountVisitor instruments the functions of a file within the scope of the instrumentation
+type funcCountVisitor struct {
+	in          *Instrumentation
+	fset        *token.FileSet
+	pkg         string
+	file        string
+	transformed *transformed
 }
 
-func (v publicFuncCountVisitor) Visit(node ast.Node) ast.Visitor {
+func (v funcCountVisitor) Visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
 	case *ast.FuncDecl:
 		return v.VisitFuncDecl(n)
+	case *ast.FuncLit:
+		return v.VisitFuncLit(n)
 	}
 	return v
 }
 
-func (v publicFuncCountVisitor) VisitFuncDecl(node *ast.FuncDecl) ast.Visitor {
+func (v funcCountVisitor) VisitFuncDecl(node *ast.FuncDecl) ast.Visitor {
 	funcName := node.Name.Name
-	if !ast.IsExported(funcName) {
-		// do not transform because this function/method is not part of the public API
+	if node.Body == nil || funcName == initFunc || funcName == "_" {
+		// external and uncallable functions
 		return v
 	}
 
-	var recv string
-	rt, err := astutil.UntypedReceiverType(node)
-	if err == nil {
-		// is method
-		recv = fmt.Sprintf("(%s).", rt)
+	fun := data.Function{
+		Pkg:  v.pkg,
+		File: v.file,
+		Name: funcName,
 	}
+	if node.Recv != nil {
+		rt, err := astutil.UntypedReceiverType(node)
+		if err != nil {
+			// receiver not addressable by data.Function (e.g., generic types)
+			return v
+		}
+		fun.Receiver = rt
+	}
+
+	var scope data.TraceScope
+	switch {
+	case fun.IsExported():
+		scope = data.ExportedScope
+	case fun.Receiver == "" || ast.IsExported(strings.TrimPrefix(fun.Receiver, "*")):
+		scope = data.FunctionsScope
+	default:
+		scope = data.MethodsScope
+	}
+	if v.in.scope.Includes(scope) {
+		v.count(fun, node.Body)
+	}
+	return v
+}
 
+func (v funcCountVisitor) VisitFuncLit(node *ast.FuncLit) ast.Visitor {
+	if v.in.scope.Includes(data.LiteralsScope) {
+		fun := data.Literal(v.pkg, v.file, v.fset.Position(node.Pos()).Line, v.fset.Position(node.End()).Line)
+		v.count(fun, node.Body)
+	}
+	return v
+}
+
+// count adds the counting of the calls of fun to the start of its body
+func (v funcCountVisitor) count(fun data.Function, body *ast.BlockStmt) {
+	counter := v.in.counter(traceName(fun))
+	inc := "Inc"
+	if v.in.callers {
+		inc = "IncCaller"
+	}
 	write := &ast.CallExpr{
 		Fun: &ast.SelectorExpr{
 			X: &ast.SelectorExpr{
 				X:   ast.NewIdent(pkgName),
 				Sel: ast.NewIdent(writerVar),
 			},
-			Sel: ast.NewIdent("Write"),
+			Sel: ast.NewIdent(inc),
 		},
 		Args: []ast.Expr{
-			&ast.CallExpr{
-				Fun: ast.NewIdent("[]byte"),
-				Args: []ast.Expr{
-					&ast.BasicLit{
-						Kind: token.STRING,
-						Value: fmt.Sprintf("\"%s;%s;%s\\n\"",
-							v.libraryName,
-							v.projectName,
-							filepath.Join(v.relPath, fmt.Sprintf("%s%s", recv, funcName))),
-					},
-				},
+			&ast.BasicLit{
+				Kind:  token.INT,
+				Value: strconv.Itoa(counter),
 			},
 		},
 	}
 
-	// add write to the start of the body
-	list := make([]ast.Stmt, 0, len(node.Body.List)+1)
+	list := make([]ast.Stmt, 0, len(body.List)+1)
 	list = append(list, &ast.ExprStmt{
 		X: write,
 	})
-	list = append(list, node.Body.List...)
-	node.Body.List = list
+	list = append(list, body.List...)
+	body.List = list
 
 	v.transformed.v = true
-
-	return v
 }
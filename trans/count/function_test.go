@@ -0,0 +1,316 @@
+package count
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sealuzh/goabs/data"
+)
+
+const libSrc = `package lib
+
+type T struct{}
+
+func (T) M() {}
+
+func F() int { return 1 }
+
+func f() {}
+`
+
+func TestInstrument(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	err = os.Mkdir(lib, os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not create library: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(lib, "go.mod"), []byte("module example.com/lib\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write go.mod: %v", err)
+	}
+	libFile := filepath.Join(lib, "lib.go")
+	err = ioutil.WriteFile(libFile, []byte(libSrc), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write library: %v", err)
+	}
+
+	// instrumenting twice must work as the library remains untouched (the second time counting callers)
+	for i := 0; i < 2; i++ {
+		in, err := Instrument(lib, dir, data.ExportedScope, i == 1)
+		if err != nil {
+			t.Fatalf("Could not instrument library: %v", err)
+		}
+
+		src, err := ioutil.ReadFile(libFile)
+		if err != nil {
+			t.Fatalf("Could not read library: %v", err)
+		}
+		if string(src) != libSrc {
+			t.Fatalf("Library was modified:\n%s", src)
+		}
+
+		if len(in.files) != 2 {
+			t.Fatalf("Expected writer and library file in overlay, was %v", in.files)
+		}
+		altered, err := ioutil.ReadFile(in.files["lib.go"])
+		if err != nil {
+			t.Fatalf("Could not read instrumented file: %v", err)
+		}
+		inc := "Inc"
+		if i == 1 {
+			inc = "IncCaller"
+		}
+		for _, exp := range []string{`"example.com/lib/ptcTraceWriter"`, `PtcTraceWriter.` + inc + `(0)`, `PtcTraceWriter.` + inc + `(1)`} {
+			if !strings.Contains(string(altered), exp) {
+				t.Errorf("Instrumented file does not contain %s:\n%s", exp, altered)
+			}
+		}
+		if exp := []string{"lib.go/(T).M", "lib.go/F"}; !reflect.DeepEqual(in.names, exp) {
+			t.Errorf("Expected traced functions %v, was %v", exp, in.names)
+		}
+
+		writer, err := ioutil.ReadFile(in.files[filepath.Join(pkgName, fileName)])
+		if err != nil {
+			t.Fatalf("Writer is not part of the overlay: %v", err)
+		}
+		if !strings.Contains(string(writer), `"lib;"`) || !strings.Contains(string(writer), `os.Getenv("GOABS_TRACE_PROJECT")`) {
+			t.Errorf("Writer does not prefix the counts with library and project:\n%s", writer)
+		}
+
+		root, args, err := in.substitute(filepath.Join(dir, "lib"))
+		if err != nil || root != lib || len(args) != 1 {
+			t.Errorf("Expected library to be replaced with -modfile, was %s %v (%v)", root, args, err)
+		}
+
+		err = in.Close()
+		if err != nil {
+			t.Fatalf("Could not close instrumentation: %v", err)
+		}
+		if _, err := os.Stat(in.dir); !os.IsNotExist(err) {
+			t.Errorf("Overlay folder %s was not removed", in.dir)
+		}
+	}
+}
+
+func TestTestPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "a_test.go"), []byte("package a_test\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write test file: %v", err)
+	}
+	pkg, mainFile, err := testPackage(dir)
+	if err != nil || pkg != "a_test" || mainFile != "" {
+		t.Fatalf("Expected test package a_test without TestMain, was '%s' ('%s', %v)", pkg, mainFile, err)
+	}
+
+	main := filepath.Join(dir, "main_test.go")
+	err = ioutil.WriteFile(main, []byte(testMainUserSrc), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write test file: %v", err)
+	}
+	if _, mainFile, _ := testPackage(dir); mainFile != main {
+		t.Fatalf("Expected TestMain in %s, was '%s'", main, mainFile)
+	}
+
+	// files excluded from the build are ignored, internal test files determine the package
+	err = ioutil.WriteFile(filepath.Join(dir, "gen_test.go"), []byte("// +build ignore\n\npackage main\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write test file: %v", err)
+	}
+	pkg, mainFile, err = testPackage(dir)
+	if err != nil || pkg != "a" || mainFile != main {
+		t.Fatalf("Expected test package a with TestMain in %s, was '%s' ('%s', %v)", main, pkg, mainFile, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "b_test.go"), []byte("package a\n\nfunc {\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write test file: %v", err)
+	}
+	if _, _, err := testPackage(dir); err == nil {
+		t.Fatalf("Expected error for test file that can not be parsed")
+	}
+}
+
+const testMainUserSrc = `package a
+
+import (
+	sys "os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code != 0 {
+		sys.Exit(code)
+	}
+}
+`
+
+func TestFlushTestMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main_test.go")
+	err = ioutil.WriteFile(main, []byte(testMainUserSrc), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write test file: %v", err)
+	}
+
+	in := &Instrumentation{importPath: "example.com/lib"}
+	src, err := in.flushTestMain(main)
+	if err != nil {
+		t.Fatalf("Could not rewrite TestMain: %v", err)
+	}
+	for _, exp := range []string{
+		`"example.com/lib/ptcTraceWriter"`,
+		"defer ptcTraceWriter.PtcTraceWriter.Flush()",
+		"ptcTraceWriter.PtcTraceWriter.Exit(code)",
+	} {
+		if !strings.Contains(string(src), exp) {
+			t.Errorf("Expected '%s' in rewritten TestMain:\n%s", exp, src)
+		}
+	}
+	if strings.Contains(string(src), "sys.Exit") || strings.Contains(string(src), `"os"`) {
+		t.Errorf("Expected os.Exit and the unused os import to be replaced:\n%s", src)
+	}
+}
+
+const scopeSrc = `package lib
+
+type T struct{}
+
+type t struct{}
+
+func (T) M() {}
+
+func (T) m() {}
+
+func (*t) M() {}
+
+func F() {
+	go func() {}()
+}
+
+func f() {}
+
+func init() {}
+
+func external()
+`
+
+func TestInstrumentScope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	err = os.MkdirAll(filepath.Join(lib, "sub"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not create library: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(lib, "go.mod"), []byte("module example.com/lib\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write go.mod: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(lib, "sub", "lib.go"), []byte(scopeSrc), os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not write library: %v", err)
+	}
+
+	tests := []struct {
+		scope data.TraceScope
+		exp   []string
+	}{
+		{"", []string{"sub/lib.go/(T).M", "sub/lib.go/F"}},
+		{data.ExportedScope, []string{"sub/lib.go/(T).M", "sub/lib.go/F"}},
+		{data.FunctionsScope, []string{"sub/lib.go/(T).M", "sub/lib.go/(T).m", "sub/lib.go/F", "sub/lib.go/f"}},
+		{data.MethodsScope, []string{"sub/lib.go/(T).M", "sub/lib.go/(T).m", "sub/lib.go/(*t).M", "sub/lib.go/F", "sub/lib.go/f"}},
+		{data.LiteralsScope, []string{"sub/lib.go/(T).M", "sub/lib.go/(T).m", "sub/lib.go/(*t).M", "sub/lib.go/F", "sub/lib.go/func:14", "sub/lib.go/f"}},
+	}
+	for _, test := range tests {
+		in, err := Instrument(lib, dir, test.scope, false)
+		if err != nil {
+			t.Fatalf("Could not instrument library: %v", err)
+		}
+		in.Close()
+		if !reflect.DeepEqual(in.names, test.exp) {
+			t.Errorf("Unexpected traced functions of scope '%s'\nexpected: %v\nwas:      %v", test.scope, test.exp, in.names)
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib/go.mod":           "module example.com/lib\n",
+		"lib/lib.go":           libSrc,
+		"cli/go.mod":           "module example.com/cli\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n",
+		"cli/cli_test.go":      "package cli\n\nimport (\n\t\"testing\"\n\n\t\"example.com/lib\"\n)\n\nfunc TestF(t *testing.T) {\n\tlib.F()\n}\n",
+		"cli/broken/a_test.go": "package broken\n\nfunc broken() int { return \"\" }\n",
+	}
+	for p, src := range files {
+		p = filepath.Join(dir, p)
+		err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		if err != nil {
+			t.Fatalf("Could not create folder: %v", err)
+		}
+		err = ioutil.WriteFile(p, []byte(src), os.ModePerm)
+		if err != nil {
+			t.Fatalf("Could not write %s: %v", p, err)
+		}
+	}
+
+	in, err := Instrument(filepath.Join(dir, "lib"), dir, data.ExportedScope, false)
+	if err != nil {
+		t.Fatalf("Could not instrument library: %v", err)
+	}
+	defer in.Close()
+
+	cli := filepath.Join(dir, "cli")
+	counts := Counts{}
+	if err := in.Exec(runtime.GOROOT(), cli, counts); err == nil {
+		t.Fatalf("Expected error for tests that can not be built")
+	}
+	if len(counts) != 0 {
+		t.Fatalf("Expected no counts of project that can not be built, was %v", counts)
+	}
+
+	err = os.RemoveAll(filepath.Join(cli, "broken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = in.Exec(runtime.GOROOT(), cli, counts)
+	if err != nil {
+		t.Fatalf("Could not trace project: %v", err)
+	}
+	exp := Counts{"lib;example.com/cli;lib.go/F": 1}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("Unexpected counts\nexpected: %v\nwas:      %v", exp, counts)
+	}
+}
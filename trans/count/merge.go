@@ -0,0 +1,123 @@
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	traceSep = ";"
+	// library;project;function of a raw trace line, optionally followed by the count
+	traceCols = 3
+	// caller;test;direct|transitive following the function of caller traces
+	callerCols = 3
+	directCall = "direct"
+)
+
+// Counts are the calls of traced functions, identified by library;project;function (and caller;test;direct|transitive of caller traces)
+type Counts map[string]uint64
+
+// Merge sums the counts of all trace files and count folders of paths and writes them to out.
+// out may be one of paths, every path must exist.
+func Merge(out string, paths ...string) error {
+	c := Counts{}
+	for _, p := range paths {
+		err := c.ReadPath(p)
+		if err != nil {
+			return err
+		}
+	}
+	return c.Write(out)
+}
+
+// ReadPath adds the counts of the trace file path or of all count files in folder path.
+func (c Counts) ReadPath(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("Could not read trace %s: %v", path, err)
+	}
+	if !fi.IsDir() {
+		return c.readFile(path)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return fmt.Errorf("Could not read counts folder %s: %v", path, err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), countsSuffix) {
+			continue
+		}
+		err = c.readFile(filepath.Join(path, f.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Counts) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Could not open trace %s: %v", path, err)
+	}
+	defer f.Close()
+
+	err = c.Read(f)
+	if err != nil {
+		return fmt.Errorf("Could not read trace %s: %v", path, err)
+	}
+	return nil
+}
+
+// Read adds the counts of a trace.
+// Lines are either aggregated (library;project;function;count or library;project;function;caller;test;direct|transitive;count)
+// or raw calls (library;project;function).
+func (c Counts) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" {
+			continue
+		}
+
+		cols := strings.Split(l, traceSep)
+		switch len(cols) {
+		case traceCols:
+			c[l]++
+		case traceCols + 1, traceCols + callerCols + 1:
+			last := len(cols) - 1
+			n, err := strconv.ParseUint(cols[last], 10, 64)
+			if err != nil {
+				return fmt.Errorf("Invalid count in trace line '%s'", l)
+			}
+			c[strings.Join(cols[:last], traceSep)] += n
+		default:
+			return fmt.Errorf("Invalid trace line '%s'", l)
+		}
+	}
+	return s.Err()
+}
+
+// Write writes the counts sorted by library, project, and function to path.
+func (c Counts) Write(path string) error {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s%s%d\n", k, traceSep, c[k])
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
@@ -0,0 +1,56 @@
+package count
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	counts := filepath.Join(dir, "counts")
+	err = os.Mkdir(counts, os.ModePerm)
+	if err != nil {
+		t.Fatalf("Could not create counts dir: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(counts, "1-1.counts"):     "lib;cli;a.go/F;3\nlib;cli;a.go/(T).M;1\n",
+		filepath.Join(counts, "2-1.counts"):     "lib;cli;a.go/F;2\nlib;cli;a.go/F;cli.Test;cli.Test;direct;5\n",
+		filepath.Join(counts, "3-1.counts.tmp"): "lib;cli;a.go/F;100\n",
+		filepath.Join(dir, "trace.csv"):         "lib;cli;a.go/F\nlib;cli2;a.go/F;4\n",
+	}
+	for p, c := range files {
+		err = ioutil.WriteFile(p, []byte(c), os.ModePerm)
+		if err != nil {
+			t.Fatalf("Could not write %s: %v", p, err)
+		}
+	}
+
+	out := filepath.Join(dir, "trace.csv")
+	if err := Merge(out, out, filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("Expected error for missing trace")
+	}
+	err = Merge(out, out, counts)
+	if err != nil {
+		t.Fatalf("Could not merge: %v", err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Could not read merged trace: %v", err)
+	}
+	exp := "lib;cli2;a.go/F;4\nlib;cli;a.go/(T).M;1\nlib;cli;a.go/F;6\nlib;cli;a.go/F;cli.Test;cli.Test;direct;5\n"
+	if string(b) != exp {
+		t.Fatalf("Unexpected merged trace\nexpected:\n%s\nwas:\n%s", exp, b)
+	}
+
+	if err := (Counts{}).Read(strings.NewReader("lib;cli\n")); err == nil {
+		t.Fatalf("Expected error for invalid trace line")
+	}
+}
@@ -0,0 +1,122 @@
+package count
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Usage is the usage of a library function
+type Usage struct {
+	Library  string `json:"library"`
+	Function string `json:"function"`
+	Calls    uint64 `json:"calls"`
+	Projects int    `json:"projects"`         // number of projects calling the function
+	Direct   uint64 `json:"direct,omitempty"` // calls by a function outside the library (caller traces only)
+	Tests    int    `json:"tests,omitempty"`  // number of tests calling the function (caller traces only)
+}
+
+// Ranking ranks the library functions by their calls, per project and combined over all projects
+type Ranking struct {
+	Combined []Usage            `json:"combined"`
+	Projects map[string][]Usage `json:"projects"`
+}
+
+// usage accumulates the usage of a function
+type usage struct {
+	u        Usage
+	projects map[string]bool
+	tests    map[string]bool
+}
+
+func (u *usage) add(project string, cols []string, calls uint64) {
+	u.u.Calls += calls
+	u.projects[project] = true
+	if len(cols) == traceCols+callerCols {
+		if cols[traceCols+2] == directCall {
+			u.u.Direct += calls
+		}
+		if test := cols[traceCols+1]; test != "" {
+			u.tests[project+traceSep+test] = true
+		}
+	}
+}
+
+type usages map[[2]string]*usage
+
+func (us usages) get(lib, fun string) *usage {
+	u, ok := us[[2]string{lib, fun}]
+	if !ok {
+		u = &usage{
+			u:        Usage{Library: lib, Function: fun},
+			projects: map[string]bool{},
+			tests:    map[string]bool{},
+		}
+		us[[2]string{lib, fun}] = u
+	}
+	return u
+}
+
+func (us usages) sorted() []Usage {
+	ret := make([]Usage, 0, len(us))
+	for _, u := range us {
+		u.u.Projects = len(u.projects)
+		u.u.Tests = len(u.tests)
+		ret = append(ret, u.u)
+	}
+	sortUsages(ret)
+	return ret
+}
+
+// Rank ranks the traced functions by their calls.
+func (c Counts) Rank() Ranking {
+	combined := usages{}
+	projects := map[string]usages{}
+	for k, calls := range c {
+		cols := strings.Split(k, traceSep)
+		if len(cols) != traceCols && len(cols) != traceCols+callerCols {
+			continue
+		}
+		lib, project, fun := cols[0], cols[1], cols[2]
+		combined.get(lib, fun).add(project, cols, calls)
+		if projects[project] == nil {
+			projects[project] = usages{}
+		}
+		projects[project].get(lib, fun).add(project, cols, calls)
+	}
+
+	r := Ranking{
+		Combined: combined.sorted(),
+		Projects: make(map[string][]Usage, len(projects)),
+	}
+	for p, us := range projects {
+		r.Projects[p] = us.sorted()
+	}
+	return r
+}
+
+// sortUsages sorts by calls, then by projects, then by library and function
+func sortUsages(us []Usage) {
+	sort.Slice(us, func(i, j int) bool {
+		if us[i].Calls != us[j].Calls {
+			return us[i].Calls > us[j].Calls
+		}
+		if us[i].Projects != us[j].Projects {
+			return us[i].Projects > us[j].Projects
+		}
+		if us[i].Library != us[j].Library {
+			return us[i].Library < us[j].Library
+		}
+		return us[i].Function < us[j].Function
+	})
+}
+
+// Write writes the ranking as indented JSON to path.
+func (r Ranking) Write(path string) error {
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0666)
+}
@@ -0,0 +1,55 @@
+package count
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	c := Counts{
+		"lib;a;f.go/F": 10,
+		"lib;a;f.go/G": 1,
+		"lib;b;f.go/G": 20,
+		"lib;b;f.go/H": 10,
+	}
+	r := c.Rank()
+
+	exp := []Usage{
+		{Library: "lib", Function: "f.go/G", Calls: 21, Projects: 2},
+		{Library: "lib", Function: "f.go/F", Calls: 10, Projects: 1},
+		{Library: "lib", Function: "f.go/H", Calls: 10, Projects: 1},
+	}
+	if !reflect.DeepEqual(r.Combined, exp) {
+		t.Errorf("Unexpected combined ranking\nexpected: %v\nwas:      %v", exp, r.Combined)
+	}
+
+	expA := []Usage{
+		{Library: "lib", Function: "f.go/F", Calls: 10, Projects: 1},
+		{Library: "lib", Function: "f.go/G", Calls: 1, Projects: 1},
+	}
+	if !reflect.DeepEqual(r.Projects["a"], expA) {
+		t.Errorf("Unexpected ranking of project a\nexpected: %v\nwas:      %v", expA, r.Projects["a"])
+	}
+	if len(r.Projects) != 2 {
+		t.Errorf("Expected rankings of 2 projects, was %d", len(r.Projects))
+	}
+}
+
+func TestRankCallers(t *testing.T) {
+	c := Counts{
+		"lib;a;f.go/F;example.com/a.helper;example.com/a.TestX;direct":    3,
+		"lib;a;f.go/F;example.com/a.TestY;example.com/a.TestY;direct":     2,
+		"lib;a;f.go/F;example.com/a.TestY;example.com/a.TestY;transitive": 4,
+		"lib;b;f.go/F;example.com/b.init;;direct":                         1,
+	}
+	r := c.Rank()
+
+	exp := []Usage{{Library: "lib", Function: "f.go/F", Calls: 10, Projects: 2, Direct: 6, Tests: 2}}
+	if !reflect.DeepEqual(r.Combined, exp) {
+		t.Errorf("Unexpected combined ranking\nexpected: %v\nwas:      %v", exp, r.Combined)
+	}
+	expB := []Usage{{Library: "lib", Function: "f.go/F", Calls: 1, Projects: 1, Direct: 1}}
+	if !reflect.DeepEqual(r.Projects["b"], expB) {
+		t.Errorf("Unexpected ranking of project b\nexpected: %v\nwas:      %v", expB, r.Projects["b"])
+	}
+}
@@ -0,0 +1,435 @@
+package count
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sealuzh/goabs/utils/astutil"
+	"github.com/sealuzh/goabs/utils/fsutil"
+)
+
+const (
+	countsFolder   = "counts"
+	countsSuffix   = ".counts"
+	testMainFile   = "goabs_trace_main_test.go"
+	testMainFunc   = "TestMain"
+	osPkg          = "os"
+	exitFunc       = "Exit"
+	flushFunc      = "Flush"
+	xTestPkgSuffix = "_test"
+	flushInterval  = time.Second
+	// projectEnvVar names the project whose tests are executed
+	projectEnvVar = "GOABS_TRACE_PROJECT"
+)
+
+// writerSrc is the trace writer package, which counts the calls of every traced function with an atomic counter.
+// With callers, it counts the calls per nearest caller outside the library, running test, and whether the call is direct instead.
+// The counts are flushed periodically to a file per process, every flush rewrites the file with the current counts.
+const writerSrc = `package %[1]s
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var %[2]s = newTraceWriter(%[3]q, %[4]q, %[5]q, %[6]d, []string{
+%[7]s})
+
+const (
+	maxDepth   = 128
+	testRunner = "testing.tRunner"
+)
+
+type traceWriter struct {
+	path    string
+	prefix  string
+	library string
+	names   []string
+	counts  []uint64
+	calls   map[call]uint64
+	l       sync.Mutex
+}
+
+// call is a call of function fn by caller, the nearest function outside the library, while test was running
+type call struct {
+	fn     int
+	caller string
+	test   string
+	direct bool
+}
+
+func newTraceWriter(dir, prefix, library string, interval time.Duration, names []string) *traceWriter {
+	tw := &traceWriter{
+		path:    filepath.Join(dir, fmt.Sprintf("%%d-%%d%[8]s", os.Getpid(), time.Now().UnixNano())),
+		prefix:  prefix + os.Getenv(%[9]q) + ";",
+		library: library,
+		names:   names,
+		counts:  make([]uint64, len(names)),
+		calls:   map[call]uint64{},
+	}
+	go func() {
+		for range time.Tick(interval) {
+			tw.Flush()
+		}
+	}()
+	return tw
+}
+
+// Inc counts a call of function i
+func (tw *traceWriter) Inc(i int) {
+	atomic.AddUint64(&tw.counts[i], 1)
+}
+
+// IncCaller counts a call of function i by its caller
+func (tw *traceWriter) IncCaller(i int) {
+	// skip Callers, IncCaller, and function i
+	pcs := make([]uintptr, maxDepth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	c := call{fn: i}
+	prev := ""
+	for first := true; ; first = false {
+		f, more := frames.Next()
+		inLibrary := tw.inLibrary(f.Function)
+		if first {
+			// called by another function of the library otherwise
+			c.direct = !inLibrary
+		}
+		if c.caller == "" && !inLibrary {
+			c.caller = f.Function
+		}
+		if f.Function == testRunner {
+			c.test = prev
+			break
+		}
+		prev = f.Function
+		if !more {
+			break
+		}
+	}
+
+	tw.l.Lock()
+	tw.calls[c]++
+	tw.l.Unlock()
+}
+
+// inLibrary reports whether the function fn belongs to the library (or its vendored copy)
+func (tw *traceWriter) inLibrary(fn string) bool {
+	i := strings.Index(fn, tw.library)
+	if i < 0 || (i > 0 && !strings.HasSuffix(fn[:i], "/vendor/")) {
+		return false
+	}
+	rest := fn[i+len(tw.library):]
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/")
+}
+
+// Flush writes the counts of all called functions to the file of the process
+func (tw *traceWriter) Flush() {
+	tw.l.Lock()
+	defer tw.l.Unlock()
+
+	var buf bytes.Buffer
+	for i, name := range tw.names {
+		c := atomic.LoadUint64(&tw.counts[i])
+		if c > 0 {
+			fmt.Fprintf(&buf, "%%s%%s;%%d\n", tw.prefix, name, c)
+		}
+	}
+	for c, n := range tw.calls {
+		kind := "transitive"
+		if c.direct {
+			kind = "direct"
+		}
+		fmt.Fprintf(&buf, "%%s%%s;%%s;%%s;%%s;%%d\n", tw.prefix, tw.names[c.fn], c.caller, c.test, kind, n)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+
+	// rename is atomic, hence the file is never read partially written
+	tmp := tw.path + ".tmp"
+	err := ioutil.WriteFile(tmp, buf.Bytes(), 0666)
+	if err == nil {
+		err = os.Rename(tmp, tw.path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not flush PTC trace counts: %%v\n", err)
+	}
+}
+
+// Exit flushes the counts and exits the process with code
+func (tw *traceWriter) Exit(code int) {
+	tw.Flush()
+	os.Exit(code)
+}
+`
+
+// testMainSrc flushes the counts when the tests of a package finished
+const testMainSrc = `package %s
+
+import (
+	"os"
+	"testing"
+
+	%s %q
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	%s.%s.Flush()
+	os.Exit(code)
+}
+`
+
+func (in *Instrumentation) writerPkg() string {
+	return in.importPath + "/" + pkgName
+}
+
+func (in *Instrumentation) countsDir() string {
+	return filepath.Join(in.dir, countsFolder)
+}
+
+// counter returns the counter of the traced function name
+func (in *Instrumentation) counter(name string) int {
+	in.names = append(in.names, name)
+	return len(in.names) - 1
+}
+
+func (in *Instrumentation) createWriter(libraryName string) error {
+	var names bytes.Buffer
+	for _, n := range in.names {
+		fmt.Fprintf(&names, "\t%q,\n", n)
+	}
+
+	// the writer package only exists in the overlay
+	return in.save(
+		filepath.Join(pkgName, fileName),
+		[]byte(fmt.Sprintf(writerSrc,
+			pkgName, writerVar,
+			in.countsDir(), libraryName+traceSep, in.importPath, flushInterval,
+			names.String(), countsSuffix, projectEnvVar,
+		)),
+	)
+}
+
+// addTestMains adds the flush of the counts at exit to every test package of project to the overlay replace.
+// Packages without TestMain get a TestMain, in the test file declaring an existing TestMain the counts are flushed
+// when TestMain returns and before os.Exit.
+func (in *Instrumentation) addTestMains(project string, replace map[string]string) error {
+	return filepath.Walk(project, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(project, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && (!fsutil.IsValidDir(rel) || info.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+
+		pkg, mainFile, err := testPackage(path)
+		if err != nil {
+			return err
+		}
+		if pkg == "" {
+			return nil
+		}
+
+		if mainFile == "" {
+			altered, err := in.write(testMainFile, []byte(fmt.Sprintf(testMainSrc, pkg, pkgName, in.writerPkg(), pkgName, writerVar)))
+			if err != nil {
+				return err
+			}
+			replace[filepath.Join(path, testMainFile)] = altered
+			return nil
+		}
+
+		src, err := in.flushTestMain(mainFile)
+		if err != nil {
+			fmt.Printf("WARNING - counts of package %s are not flushed at exit: %v\n", rel, err)
+			return nil
+		}
+		altered, err := in.write(filepath.Base(mainFile), src)
+		if err != nil {
+			return err
+		}
+		replace[mainFile] = altered
+		return nil
+	})
+}
+
+// testPackage returns the package of the test files of dir built by go test (go/build) and the test file declaring
+// TestMain (empty if none). The package is empty if dir has no test files; it is the external test package
+// (<name>_test) if dir has neither package nor internal test files.
+func testPackage(dir string) (string, string, error) {
+	p, err := build.ImportDir(dir, 0)
+	if _, ok := err.(*build.NoGoError); ok {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("Could not import package %s: %v", dir, err)
+	}
+	if len(p.TestGoFiles) == 0 && len(p.XTestGoFiles) == 0 {
+		return "", "", nil
+	}
+
+	pkg := p.Name
+	if len(p.GoFiles) == 0 && len(p.TestGoFiles) == 0 {
+		pkg += xTestPkgSuffix
+	}
+
+	mainFile := ""
+	for _, fn := range append(p.TestGoFiles, p.XTestGoFiles...) {
+		path := filepath.Join(dir, fn)
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			return "", "", fmt.Errorf("Could not parse test file: %v", err)
+		}
+		if testMain(f) != nil {
+			mainFile = path
+		}
+	}
+	return pkg, mainFile, nil
+}
+
+// flushTestMain returns the test file path, in which the counts are flushed when TestMain returns and
+// every os.Exit call flushes the counts before exiting
+func (in *Instrumentation) flushTestMain(path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	fd := testMain(f)
+	if fd == nil || fd.Body == nil {
+		return nil, fmt.Errorf("No TestMain in %s", path)
+	}
+
+	osName := ""
+	var osImport *ast.ImportSpec
+	for _, is := range f.Imports {
+		if is.Path.Value != strconv.Quote(osPkg) {
+			continue
+		}
+		osName = osPkg
+		if is.Name != nil {
+			osName = is.Name.Name
+		}
+		osImport = is
+	}
+	if osName == "." {
+		return nil, fmt.Errorf("os is dot-imported in %s", path)
+	}
+
+	writer := astutil.AddImport(in.writerPkg(), f)
+	writerMethod := func(name string) *ast.SelectorExpr {
+		return &ast.SelectorExpr{
+			X:   &ast.SelectorExpr{X: ast.NewIdent(writer), Sel: ast.NewIdent(writerVar)},
+			Sel: ast.NewIdent(name),
+		}
+	}
+
+	// os.Exit does not run deferred functions
+	osUsed := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && isOsSelector(sel, osName) && sel.Sel.Name == exitFunc {
+				n.Fun = writerMethod(exitFunc)
+			}
+		case *ast.SelectorExpr:
+			osUsed = osUsed || isOsSelector(n, osName)
+		}
+		return true
+	})
+	if osImport != nil && !osUsed {
+		removeImport(f, osImport)
+	}
+
+	// the testing package exits after TestMain returned
+	flush := &ast.DeferStmt{Call: &ast.CallExpr{Fun: writerMethod(flushFunc)}}
+	fd.Body.List = append([]ast.Stmt{flush}, fd.Body.List...)
+
+	var buf bytes.Buffer
+	err = printer.Fprint(&buf, fset, f)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isOsSelector reports whether sel selects from the os package imported as osName.
+// Package identifiers are unresolved, in contrast to local variables of the same name.
+func isOsSelector(sel *ast.SelectorExpr, osName string) bool {
+	id, ok := sel.X.(*ast.Ident)
+	return ok && osName != "" && id.Name == osName && id.Obj == nil
+}
+
+// removeImport removes the import is from f
+func removeImport(f *ast.File, is *ast.ImportSpec) {
+	decls := f.Decls[:0]
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if ok && gd.Tok == token.IMPORT {
+			specs := gd.Specs[:0]
+			for _, s := range gd.Specs {
+				if s != is {
+					specs = append(specs, s)
+				}
+			}
+			gd.Specs = specs
+			if len(specs) == 0 {
+				continue
+			}
+		}
+		decls = append(decls, d)
+	}
+	f.Decls = decls
+
+	imports := f.Imports[:0]
+	for _, i := range f.Imports {
+		if i != is {
+			imports = append(imports, i)
+		}
+	}
+	f.Imports = imports
+}
+
+// testMain returns the TestMain declaration of f (nil if none)
+func testMain(f *ast.File) *ast.FuncDecl {
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if ok && fd.Recv == nil && fd.Name.Name == testMainFunc {
+			return fd
+		}
+	}
+	return nil
+}
+
+func (in *Instrumentation) clearCounts() error {
+	err := os.RemoveAll(in.countsDir())
+	if err != nil {
+		return fmt.Errorf("Could not clear counts folder: %v", err)
+	}
+	return os.Mkdir(in.countsDir(), os.ModePerm)
+}
@@ -0,0 +1,234 @@
+package regression
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/sealuzh/goabs/data"
+	"github.com/sealuzh/goabs/utils/astutil"
+)
+
+const (
+	timePkg = "time"
+	syncPkg = "sync"
+
+	regrStartVar = "_goptcRegrStart"
+	regrEndVar   = "_goptcRegrEnd"
+	regrSinkVar  = "_goptcRegrSink"
+	regrLockVar  = "_goptcRegrLock"
+)
+
+// injector generates the code of a regression type
+type injector interface {
+	// imports returns the packages used by the injected code
+	imports() []string
+	// decls returns package-level declarations used by the injected code; pkgs maps imports to their names in the file
+	decls(pkgs map[string]*ast.Ident) []ast.Decl
+	// stmts returns the statements prepended to the function body
+	stmts(pkgs map[string]*ast.Ident) []ast.Stmt
+}
+
+type injVisitor struct {
+	fset *token.FileSet
+	fun  data.Function
+	inj  injector
+	pkgs map[string]*ast.Ident
+}
+
+func (v *injVisitor) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.File:
+		return v.VisitFile(n)
+	case *ast.FuncDecl:
+		return v.VisitFuncDecl(n)
+	case *ast.FuncLit:
+		return v.VisitFuncLit(n)
+	}
+	return v
+}
+
+func (v *injVisitor) VisitFile(node *ast.File) ast.Visitor {
+	v.pkgs = make(map[string]*ast.Ident)
+	for _, pkg := range v.inj.imports() {
+		v.pkgs[pkg] = ast.NewIdent(astutil.AddImport(pkg, node))
+	}
+	node.Decls = append(node.Decls, v.inj.decls(v.pkgs)...)
+	return v
+}
+
+func (v *injVisitor) VisitFuncDecl(node *ast.FuncDecl) ast.Visitor {
+	if astutil.MatchingFunction(node, v.fun) {
+		node.Body.List = append(v.inj.stmts(v.pkgs), node.Body.List...)
+	}
+	return v
+}
+
+func (v *injVisitor) VisitFuncLit(node *ast.FuncLit) ast.Visitor {
+	if astutil.MatchingLiteral(v.fset, node, v.fun) {
+		node.Body.List = append(v.inj.stmts(v.pkgs), node.Body.List...)
+	}
+	return v
+}
+
+// busyInjector spins the CPU proportionally to the runtime of the function
+type busyInjector struct {
+	factor float32
+}
+
+func (i busyInjector) imports() []string {
+	return []string{timePkg}
+}
+
+func (i busyInjector) decls(pkgs map[string]*ast.Ident) []ast.Decl {
+	return nil
+}
+
+func (i busyInjector) stmts(pkgs map[string]*ast.Ident) []ast.Stmt {
+	time := pkgs[timePkg]
+	start := ast.NewIdent(regrStartVar)
+	end := ast.NewIdent(regrEndVar)
+
+	// for _goptcRegrEnd := time.Now().Add(...); time.Now().Before(_goptcRegrEnd); {}
+	spin := &ast.ForStmt{
+		Init: &ast.AssignStmt{
+			Lhs: []ast.Expr{end},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call(sel(call(sel(time, "Now")), "Add"), scaledRuntime(time, start, i.factor))},
+		},
+		Cond: call(sel(call(sel(time, "Now")), "Before"), end),
+		Body: &ast.BlockStmt{},
+	}
+
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{start},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call(sel(time, "Now"))},
+		},
+		deferred(spin),
+	}
+}
+
+// constantInjector sleeps for a constant duration
+type constantInjector struct {
+	delay data.Duration
+}
+
+func (i constantInjector) imports() []string {
+	return []string{timePkg}
+}
+
+func (i constantInjector) decls(pkgs map[string]*ast.Ident) []ast.Decl {
+	return nil
+}
+
+func (i constantInjector) stmts(pkgs map[string]*ast.Ident) []ast.Stmt {
+	return []ast.Stmt{
+		&ast.ExprStmt{X: sleepCall(pkgs[timePkg], i.delay)},
+	}
+}
+
+// allocInjector allocates a heap buffer that escapes through a package-level variable
+type allocInjector struct {
+	size int
+}
+
+func (i allocInjector) imports() []string {
+	return nil
+}
+
+func (i allocInjector) decls(pkgs map[string]*ast.Ident) []ast.Decl {
+	// var _goptcRegrSink []byte
+	return []ast.Decl{varDecl(regrSinkVar, &ast.ArrayType{Elt: ast.NewIdent("byte")})}
+}
+
+func (i allocInjector) stmts(pkgs map[string]*ast.Ident) []ast.Stmt {
+	// _goptcRegrSink = make([]byte, size)
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(regrSinkVar)},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{call(ast.NewIdent("make"), &ast.ArrayType{Elt: ast.NewIdent("byte")}, intLit(int64(i.size)))},
+		},
+	}
+}
+
+// lockInjector serialises all calls of the function with a package-level mutex and holds it for delay,
+// i.e., concurrent calls wait for each other and every call is delayed.
+// The mutex is not reentrant: directly recursive functions are refused (see Trans), indirect recursion deadlocks and
+// is reported as timeout or deadlock failure of the benchmark.
+type lockInjector struct {
+	delay data.Duration
+}
+
+func (i lockInjector) imports() []string {
+	return []string{syncPkg, timePkg}
+}
+
+func (i lockInjector) decls(pkgs map[string]*ast.Ident) []ast.Decl {
+	// var _goptcRegrLock sync.Mutex
+	return []ast.Decl{varDecl(regrLockVar, sel(pkgs[syncPkg], "Mutex"))}
+}
+
+func (i lockInjector) stmts(pkgs map[string]*ast.Ident) []ast.Stmt {
+	// _goptcRegrLock.Lock(); defer _goptcRegrLock.Unlock(); time.Sleep(delay)
+	lock := ast.NewIdent(regrLockVar)
+	return []ast.Stmt{
+		&ast.ExprStmt{X: call(sel(lock, "Lock"))},
+		&ast.DeferStmt{Call: call(sel(lock, "Unlock"))},
+		&ast.ExprStmt{X: sleepCall(pkgs[timePkg], i.delay)},
+	}
+}
+
+// scaledRuntime returns the expression time.Duration(float32(time.Since(start).Nanoseconds()) * factor)
+func scaledRuntime(timePkg, start *ast.Ident, factor float32) ast.Expr {
+	ns := call(sel(call(sel(timePkg, "Since"), start), "Nanoseconds"))
+	scaled := &ast.BinaryExpr{
+		X:  call(ast.NewIdent("float32"), ns),
+		Op: token.MUL,
+		Y: &ast.BasicLit{
+			Value: fmt.Sprintf("%f", factor),
+			Kind:  token.FLOAT,
+		},
+	}
+	return call(sel(timePkg, "Duration"), scaled)
+}
+
+// sleepCall returns the expression time.Sleep(time.Duration(d))
+func sleepCall(timePkg *ast.Ident, d data.Duration) ast.Expr {
+	return call(sel(timePkg, "Sleep"), call(sel(timePkg, "Duration"), intLit(int64(d))))
+}
+
+// deferred wraps stmt in a deferred, immediately called closure
+func deferred(stmt ast.Stmt) ast.Stmt {
+	return &ast.DeferStmt{
+		Call: call(&ast.FuncLit{
+			Type: &ast.FuncType{Params: &ast.FieldList{}},
+			Body: &ast.BlockStmt{List: []ast.Stmt{stmt}},
+		}),
+	}
+}
+
+func varDecl(name string, typ ast.Expr) ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  typ,
+		}},
+	}
+}
+
+func sel(x ast.Expr, name string) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: x, Sel: ast.NewIdent(name)}
+}
+
+func call(fun ast.Expr, args ...ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{Fun: fun, Args: args}
+}
+
+func intLit(i int64) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(i, 10)}
+}
@@ -4,10 +4,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
-	"go/printer"
 	"go/token"
-	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/sealuzh/goabs/data"
@@ -17,21 +14,53 @@ import (
 type Introducer interface {
 	Trans(f data.Function) error
 	Reset() error
+	// BuildArgs returns the additional go build flags required to build the project with the introduced regression.
+	BuildArgs() []string
 }
 
-type relIntroducer struct {
-	basePath  string
-	violation float32
+type introducer struct {
+	basePath   string
+	regression data.Regression
+	store      store
 }
 
+// NewRelative creates an introducer that rewrites the project files in place.
+// Reset restores the project with git.
 func NewRelative(basePath string, violation float32) Introducer {
-	return &relIntroducer{
-		basePath:  basePath,
-		violation: violation,
+	return New(basePath, data.Regression{Type: data.RelativeRegression, Factor: violation})
+}
+
+// NewRelativeOverlay creates an introducer that never touches the project files.
+// Altered files are written to a scratch folder within workspace (os.TempDir if empty) and
+// passed to the go command as overlay (-overlay, Go 1.16 or newer).
+func NewRelativeOverlay(basePath, workspace string, violation float32) (Introducer, error) {
+	return NewOverlay(basePath, workspace, data.Regression{Type: data.RelativeRegression, Factor: violation})
+}
+
+// New creates an introducer that rewrites the project files in place with regression r,
+// unless a function defines its own regression.
+func New(basePath string, r data.Regression) Introducer {
+	return &introducer{
+		basePath:   basePath,
+		regression: r,
+		store:      &inPlaceStore{basePath: basePath},
+	}
+}
+
+// NewOverlay is the overlay counterpart of New (see NewRelativeOverlay).
+func NewOverlay(basePath, workspace string, r data.Regression) (Introducer, error) {
+	absBasePath, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, err
 	}
+	return &introducer{
+		basePath:   absBasePath,
+		regression: r,
+		store:      newOverlayStore(workspace),
+	}, nil
 }
 
-func (i *relIntroducer) Trans(fun data.Function) error {
+func (i *introducer) Trans(fun data.Function) error {
 	filePath := filepath.Join(i.basePath, fun.Pkg, fun.File)
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, filePath, nil, parser.AllErrors)
@@ -40,49 +69,101 @@ func (i *relIntroducer) Trans(fun data.Function) error {
 		return err
 	}
 
-	v := &relRegVisitor{
-		fun:       fun,
-		violation: i.violation,
+	r := i.regression
+	if fun.Regression != nil {
+		r = fun.Regression.Or(i.regression)
 	}
 
-	ast.Walk(v, f)
-
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	v, err := newVisitor(fset, fun, r)
 	if err != nil {
-		fmt.Printf("Could not open file: %s\n", filePath)
 		return err
 	}
-	defer file.Close()
 
-	err = printer.Fprint(file, fset, f)
-	if err != nil {
-		fmt.Printf("Could not save back to file: %s\n", filePath)
-		return err
+	// a recursive call would wait for the lock held by its caller
+	if r.Type == data.LockRegression && recursive(f, fun) {
+		return fmt.Errorf("Lock regression would deadlock recursive function %s", fun.String())
 	}
 
-	return nil
+	ast.Walk(v, f)
+
+	return i.store.save(filePath, fset, f)
 }
 
-func (i *relIntroducer) Reset() error {
-	// save ast and restore it later
-	return gitReset(i.basePath)
+func (i *introducer) Reset() error {
+	return i.store.reset()
 }
 
-func gitReset(basePath string) error {
-	err := os.Chdir(basePath)
+func (i *introducer) BuildArgs() []string {
+	return i.store.buildArgs()
+}
+
+// newVisitor creates the visitor introducing regression r into function fun, which may be a function literal (see data.Literal).
+// fset resolves the lines of function literals.
+func newVisitor(fset *token.FileSet, fun data.Function, r data.Regression) (ast.Visitor, error) {
+	err := r.Validate()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	cmd := exec.Command("git", "reset", "--hard")
-	err = cmd.Run()
-	if err != nil {
-		fmt.Printf("Could not reset introduced regression with git")
-		return err
+
+	var inj injector
+	switch r.Type {
+	case data.RelativeRegression, "":
+		return &relRegVisitor{
+			fset:      fset,
+			fun:       fun,
+			violation: r.Factor,
+		}, nil
+	case data.BusyRegression:
+		inj = busyInjector{factor: r.Factor}
+	case data.ConstantRegression:
+		inj = constantInjector{delay: r.Delay}
+	case data.AllocRegression:
+		inj = allocInjector{size: r.Alloc}
+	case data.LockRegression:
+		inj = lockInjector{delay: r.Delay}
+	}
+	return &injVisitor{
+		fset: fset,
+		fun:  fun,
+		inj:  inj,
+	}, nil
+}
+
+// recursive reports whether function fun of file f calls itself directly.
+// Methods are recursive if they call the method on their receiver.
+func recursive(f *ast.File, fun data.Function) bool {
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || !astutil.MatchingFunction(fd, fun) {
+			continue
+		}
+
+		recv := ""
+		if fd.Recv != nil && len(fd.Recv.List[0].Names) > 0 {
+			recv = fd.Recv.List[0].Names[0].Name
+		}
+		found := false
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			c, ok := n.(*ast.CallExpr)
+			if !ok {
+				return !found
+			}
+			switch f := c.Fun.(type) {
+			case *ast.Ident:
+				found = found || (fd.Recv == nil && f.Name == fun.Name)
+			case *ast.SelectorExpr:
+				x, ok := f.X.(*ast.Ident)
+				found = found || (ok && recv != "" && x.Name == recv && f.Sel.Name == fun.Name)
+			}
+			return !found
+		})
+		return found
 	}
-	return nil
+	return false
 }
 
 type relRegVisitor struct {
+	fset           *token.FileSet
 	fun            data.Function
 	violation      float32
 	timeImportName string
@@ -94,6 +175,8 @@ func (v *relRegVisitor) Visit(node ast.Node) ast.Visitor {
 		return v.VisitFile(n)
 	case *ast.FuncDecl:
 		return v.VisitFuncDecl(n)
+	case *ast.FuncLit:
+		return v.VisitFuncLit(n)
 	}
 	return v
 }
@@ -106,13 +189,23 @@ func (v *relRegVisitor) VisitFile(node *ast.File) ast.Visitor {
 }
 
 func (v *relRegVisitor) VisitFuncDecl(node *ast.FuncDecl) ast.Visitor {
-	if !astutil.MatchingFunction(node, v.fun) {
-		return v
+	if astutil.MatchingFunction(node, v.fun) {
+		v.inject(node.Body)
 	}
+	return v
+}
 
+func (v *relRegVisitor) VisitFuncLit(node *ast.FuncLit) ast.Visitor {
+	if astutil.MatchingLiteral(v.fset, node, v.fun) {
+		v.inject(node.Body)
+	}
+	return v
+}
+
+// inject prepends the regression to function body b
+func (v *relRegVisitor) inject(b *ast.BlockStmt) {
 	newNodesCount := 2
 
-	b := node.Body
 	list := make([]ast.Stmt, 0, len(b.List)+newNodesCount)
 
 	// time pkg selector
@@ -159,69 +252,9 @@ func (v *relRegVisitor) VisitFuncDecl(node *ast.FuncDecl) ast.Visitor {
 
 	list = append(list, b.List...)
 	b.List = list
-	return v
 }
 
 func (v *relRegVisitor) sleepStmt(timePkg, startVarName *ast.Ident) *ast.CallExpr {
-	// duration
-	dur := &ast.CallExpr{
-		Fun: &ast.SelectorExpr{
-			X:   timePkg,
-			Sel: ast.NewIdent("Since"),
-		},
-		Args: []ast.Expr{
-			startVarName,
-		},
-	}
-
-	// nanoseconds of duration
-	ns := &ast.CallExpr{
-		Fun: &ast.SelectorExpr{
-			X:   dur,
-			Sel: ast.NewIdent("Nanoseconds"),
-		},
-		Args: []ast.Expr{},
-	}
-
-	// nanoseconds in float32
-	fns := &ast.CallExpr{
-		Fun: ast.NewIdent("float32"),
-		Args: []ast.Expr{
-			ns,
-		},
-	}
-
-	// sleep time
-	sleepTime := &ast.BinaryExpr{
-		X:  fns,
-		Op: token.MUL,
-		Y: &ast.BasicLit{
-			Value: fmt.Sprintf("%f", v.violation),
-			Kind:  token.FLOAT,
-		},
-	}
-
-	// duration of sleep time
-	sleepDuration := &ast.CallExpr{
-		Fun: &ast.SelectorExpr{
-			X:   timePkg,
-			Sel: ast.NewIdent("Duration"),
-		},
-		Args: []ast.Expr{
-			sleepTime,
-		},
-	}
-
 	// sleep statement
-	sleep := &ast.CallExpr{
-		Fun: &ast.SelectorExpr{
-			X:   timePkg,
-			Sel: ast.NewIdent("Sleep"),
-		},
-		Args: []ast.Expr{
-			sleepDuration,
-		},
-	}
-
-	return sleep
+	return call(sel(timePkg, "Sleep"), scaledRuntime(timePkg, startVarName, v.violation))
 }
@@ -2,6 +2,7 @@ package regression
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/sealuzh/goabs/data"
@@ -31,6 +33,7 @@ func testRelRegIntroFunc(srcFunc srcFunc, fun data.Function, t *testing.T) {
 	}
 
 	visitor := &relRegVisitor{
+		fset:      fset,
 		fun:       fun,
 		violation: 1.0,
 	}
@@ -103,6 +106,80 @@ func testRelRegFile(srcFunc srcFunc, fun data.Function, t *testing.T) {
 	}
 }
 
+func testRelRegOverlay(srcFunc srcFunc, fun data.Function, t *testing.T) {
+	src, srcOut := srcFunc()
+
+	dir, err := ioutil.TempDir("", "regression_test")
+	if err != nil {
+		t.Errorf("could not create temp dir: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	tmpFilePath := filepath.Join(dir, fun.File)
+	err = ioutil.WriteFile(tmpFilePath, []byte(src), os.ModePerm)
+	if err != nil {
+		t.Errorf("could not write to file: %v", err)
+		return
+	}
+
+	ri, err := NewRelativeOverlay(dir, dir, 1.0)
+	if err != nil {
+		t.Errorf("could not create overlay introducer: %v", err)
+		return
+	}
+	err = ri.Trans(fun)
+	if err != nil {
+		t.Errorf("could not transform file: %v", err)
+		return
+	}
+
+	// original file must not be touched
+	fc, err := ioutil.ReadFile(tmpFilePath)
+	if err != nil || string(fc) != src {
+		t.Errorf("original file was altered")
+	}
+
+	args := ri.BuildArgs()
+	if len(args) != 1 || !strings.HasPrefix(args[0], "-overlay=") {
+		t.Errorf("unexpected build args: %v", args)
+		return
+	}
+	b, err := ioutil.ReadFile(strings.TrimPrefix(args[0], "-overlay="))
+	if err != nil {
+		t.Errorf("could not read overlay: %v", err)
+		return
+	}
+	var o overlay
+	err = json.Unmarshal(b, &o)
+	if err != nil {
+		t.Errorf("could not parse overlay: %v", err)
+		return
+	}
+	altered, ok := o.Replace[tmpFilePath]
+	if !ok {
+		t.Errorf("overlay does not replace %s: %v", tmpFilePath, o.Replace)
+		return
+	}
+	fc, err = ioutil.ReadFile(altered)
+	out := removeAllWhiteSpaces(string(fc))
+	srcOut = removeAllWhiteSpaces(srcOut)
+	if out != srcOut {
+		t.Errorf("Unexpected Output\n-- expected --\n%s\n-- was --\n%s\n", srcOut, out)
+	}
+
+	err = ri.Reset()
+	if err != nil {
+		t.Errorf("could not reset: %v", err)
+	}
+	if _, err := os.Stat(altered); !os.IsNotExist(err) {
+		t.Errorf("overlay file not removed after reset: %s", altered)
+	}
+	if len(ri.BuildArgs()) != 0 {
+		t.Errorf("build args not empty after reset")
+	}
+}
+
 func removeAllWhiteSpaces(str string) string {
 	return strings.Map(func(r rune) rune {
 		if unicode.IsSpace(r) {
@@ -208,6 +285,10 @@ func TestRelRegFileVoid(t *testing.T) {
 	fun := fun("", "tmp.go", "")
 	testRelRegFile(funSrcVoid, fun, t)
 }
+func TestRelRegOverlayVoid(t *testing.T) {
+	fun := fun("", "tmp.go", "")
+	testRelRegOverlay(funSrcVoid, fun, t)
+}
 
 // methods (value receiver) with no return value test
 
@@ -376,3 +457,226 @@ func TestRelRegFilePointerRecvReturn(t *testing.T) {
 	fun := fun("", "tmp.go", "*T")
 	testRelRegFile(funSrcPointerRecvReturn, fun, t)
 }
+func TestRelRegOverlayPointerRecvReturn(t *testing.T) {
+	fun := fun("", "tmp.go", "*T")
+	testRelRegOverlay(funSrcPointerRecvReturn, fun, t)
+}
+
+func TestInjectors(t *testing.T) {
+	src := `package test
+
+func test() {
+	doSomething()
+}
+`
+	tests := []struct {
+		r   data.Regression
+		out string
+	}{
+		{
+			r: data.Regression{Type: data.BusyRegression, Factor: 0.5},
+			out: `package test
+
+import "time"
+
+func test() {
+	_goptcRegrStart := time.Now()
+	defer func() {
+		for _goptcRegrEnd := time.Now().Add(time.Duration(float32(time.Since(_goptcRegrStart).Nanoseconds()) * 0.500000)); time.Now().Before(_goptcRegrEnd); {
+		}
+	}()
+	doSomething()
+}
+`,
+		},
+		{
+			r: data.Regression{Type: data.ConstantRegression, Delay: data.Duration(time.Millisecond)},
+			out: `package test
+
+import "time"
+
+func test() {
+	time.Sleep(time.Duration(1000000))
+	doSomething()
+}
+`,
+		},
+		{
+			r: data.Regression{Type: data.AllocRegression, Alloc: 1024},
+			out: `package test
+
+func test() {
+	_goptcRegrSink = make([]byte, 1024)
+	doSomething()
+}
+
+var _goptcRegrSink []byte
+`,
+		},
+		{
+			r: data.Regression{Type: data.LockRegression, Delay: data.Duration(time.Millisecond)},
+			out: `package test
+
+import "sync"
+import "time"
+
+func test() {
+	_goptcRegrLock.Lock()
+	defer _goptcRegrLock.Unlock()
+	time.Sleep(time.Duration(1000000))
+	doSomething()
+}
+
+var _goptcRegrLock sync.Mutex
+`,
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", src, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("Could not parse file: %v", err)
+		}
+
+		v, err := newVisitor(fset, fun("", "", ""), test.r)
+		if err != nil {
+			t.Fatalf("Could not create visitor for %s: %v", test.r.Type, err)
+		}
+		ast.Walk(v, f)
+
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, f)
+		out := removeAllWhiteSpaces(buf.String())
+		if exp := removeAllWhiteSpaces(test.out); out != exp {
+			t.Errorf("Unexpected output for %s\n-- expected --\n%s\n-- was --\n%s\n", test.r.Type, test.out, buf.String())
+		}
+	}
+}
+
+func TestInvalidRegressions(t *testing.T) {
+	for _, r := range []data.Regression{
+		{Type: data.ConstantRegression},
+		{Type: data.AllocRegression, Alloc: -1},
+		{Type: data.LockRegression},
+		{Type: data.RelativeRegression},
+		{Type: data.BusyRegression, Factor: -1},
+	} {
+		if _, err := newVisitor(token.NewFileSet(), fun("", "", ""), r); err == nil {
+			t.Errorf("Expected error for %s regression %+v", r.Type, r)
+		}
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func test(n int) {
+	if n > 0 {
+		test(n - 1)
+	}
+}
+
+func (t *T) Rec() {
+	func() { t.Rec() }()
+}
+
+func (t *T) Other() {
+	t.Rec()
+	other()
+}
+
+func other() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Could not parse file: %v", err)
+	}
+	tests := []struct {
+		fun data.Function
+		exp bool
+	}{
+		{data.Function{Name: "test"}, true},
+		{data.Function{Name: "Rec", Receiver: "*T"}, true},
+		{data.Function{Name: "Other", Receiver: "*T"}, false},
+		{data.Function{Name: "other"}, false},
+	}
+	for _, test := range tests {
+		if r := recursive(f, test.fun); r != test.exp {
+			t.Errorf("Unexpected recursion of %s: expected %t", test.fun.Name, test.exp)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	src := `package test
+
+func test() {
+	f := func() {
+		doSomething()
+	}
+	f()
+}
+`
+	tests := []struct {
+		r   data.Regression
+		out string
+	}{
+		{
+			r: data.Regression{Type: data.RelativeRegression, Factor: 1},
+			out: `package test
+
+import "time"
+
+func test() {
+	f := func() {
+		_goptcRegrStart := time.Now()
+		defer func() {
+			time.Sleep(time.Duration(float32(time.Since(_goptcRegrStart).Nanoseconds()) * 1.000000))
+		}()
+		doSomething()
+	}
+	f()
+}
+`,
+		},
+		{
+			r: data.Regression{Type: data.ConstantRegression, Delay: data.Duration(time.Millisecond)},
+			out: `package test
+
+import "time"
+
+func test() {
+	f := func() {
+		time.Sleep(time.Duration(1000000))
+		doSomething()
+	}
+	f()
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", src, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("Could not parse file: %v", err)
+		}
+
+		v, err := newVisitor(fset, data.Literal("", "", 4, 6), test.r)
+		if err != nil {
+			t.Fatalf("Could not create visitor for %s: %v", test.r.Type, err)
+		}
+		ast.Walk(v, f)
+
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, f)
+		out := removeAllWhiteSpaces(buf.String())
+		if exp := removeAllWhiteSpaces(test.out); out != exp {
+			t.Errorf("Unexpected output for %s\n-- expected --\n%s\n-- was --\n%s\n", test.r.Type, test.out, buf.String())
+		}
+	}
+}
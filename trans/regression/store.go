@@ -0,0 +1,144 @@
+package regression
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const (
+	overlayFolderPrefix = "goabs-overlay-"
+	overlayFile         = "overlay.json"
+	cmdArgsOverlay      = "-overlay=%s"
+)
+
+// store persists the transformed files of a project
+type store interface {
+	save(filePath string, fset *token.FileSet, f *ast.File) error
+	reset() error
+	buildArgs() []string
+}
+
+type inPlaceStore struct {
+	basePath string
+}
+
+func (s *inPlaceStore) save(filePath string, fset *token.FileSet, f *ast.File) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		fmt.Printf("Could not open file: %s\n", filePath)
+		return err
+	}
+	defer file.Close()
+
+	err = printer.Fprint(file, fset, f)
+	if err != nil {
+		fmt.Printf("Could not save back to file: %s\n", filePath)
+		return err
+	}
+	return nil
+}
+
+func (s *inPlaceStore) reset() error {
+	return gitReset(s.basePath)
+}
+
+func (s *inPlaceStore) buildArgs() []string {
+	return nil
+}
+
+func gitReset(basePath string) error {
+	cmd := exec.Command("git", "reset", "--hard")
+	cmd.Dir = basePath
+	err := cmd.Run()
+	if err != nil {
+		fmt.Printf("Could not reset introduced regression with git")
+		return err
+	}
+	return nil
+}
+
+// overlay is the JSON format of go build's -overlay flag
+type overlay struct {
+	Replace map[string]string
+}
+
+type overlayStore struct {
+	workspace string
+	dir       string
+	replace   map[string]string
+}
+
+func newOverlayStore(workspace string) *overlayStore {
+	return &overlayStore{
+		workspace: workspace,
+		replace:   map[string]string{},
+	}
+}
+
+func (s *overlayStore) save(filePath string, fset *token.FileSet, f *ast.File) error {
+	if s.dir == "" {
+		dir, err := ioutil.TempDir(s.workspace, overlayFolderPrefix)
+		if err != nil {
+			fmt.Printf("Could not create overlay folder in '%s'\n", s.workspace)
+			return err
+		}
+		s.dir = dir
+	}
+
+	altered, ok := s.replace[filePath]
+	if !ok {
+		altered = filepath.Join(s.dir, fmt.Sprintf("%d_%s", len(s.replace), filepath.Base(filePath)))
+	}
+
+	file, err := os.Create(altered)
+	if err != nil {
+		fmt.Printf("Could not create overlay file: %s\n", altered)
+		return err
+	}
+	defer file.Close()
+
+	err = printer.Fprint(file, fset, f)
+	if err != nil {
+		fmt.Printf("Could not save overlay file: %s\n", altered)
+		return err
+	}
+
+	s.replace[filePath] = altered
+	return s.writeOverlay()
+}
+
+func (s *overlayStore) writeOverlay() error {
+	b, err := json.Marshal(overlay{Replace: s.replace})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, overlayFile), b, os.ModePerm)
+}
+
+func (s *overlayStore) reset() error {
+	s.replace = map[string]string{}
+	if s.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.dir)
+	if err != nil {
+		fmt.Printf("Could not remove overlay folder: %s\n", s.dir)
+		return err
+	}
+	s.dir = ""
+	return nil
+}
+
+func (s *overlayStore) buildArgs() []string {
+	if len(s.replace) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf(cmdArgsOverlay, filepath.Join(s.dir, overlayFile))}
+}
@@ -3,6 +3,7 @@ package astutil
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
 
 	"github.com/sealuzh/goabs/data"
 )
@@ -54,3 +55,9 @@ func MatchingFunction(node *ast.FuncDecl, fun data.Function) bool {
 	// no parameter/return type matching necessary as Go does not provide Function-overloading
 	return match
 }
+
+// MatchingLiteral reports whether node is the function literal fun (see data.Literal), i.e., starts at its line.
+func MatchingLiteral(fset *token.FileSet, node *ast.FuncLit, fun data.Function) bool {
+	line, ok := fun.LiteralLine()
+	return ok && node.Pos().IsValid() && fset.Position(node.Pos()).Line == line
+}
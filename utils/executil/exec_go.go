@@ -31,6 +31,10 @@ func Env(goRoot, goPath string) []string {
 			ret = append(ret, e)
 		}
 	}
+	// make sure GOPATH is set even if it was not part of the environment
+	if goPath != "" {
+		ret = SetEnv(ret, goPathVariable, goPath)
+	}
 	return ret
 }
 
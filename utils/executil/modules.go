@@ -0,0 +1,175 @@
+package executil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	GoModFile         = "go.mod"
+	goModuleDirective = "module"
+	goModVendorFile   = "vendor/modules.txt"
+	go111ModuleVar    = "GO111MODULE"
+	goFlagsVar        = "GOFLAGS"
+	goFlagsMod        = "-mod="
+	goModVendor       = "vendor"
+)
+
+// ModuleRoot returns the root directory of the module dir belongs to.
+// The second return value is false if dir is not part of a module.
+func ModuleRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if IsModuleRoot(dir) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// IsModuleRoot reports whether dir is the root directory of a module (i.e., contains a go.mod file).
+func IsModuleRoot(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, GoModFile))
+	return err == nil && !fi.IsDir()
+}
+
+func IsModule(dir string) bool {
+	_, ok := ModuleRoot(dir)
+	return ok
+}
+
+// ModulePath returns the module path declared in the go.mod file of the module dir belongs to.
+func ModulePath(dir string) (string, error) {
+	root, ok := ModuleRoot(dir)
+	if !ok {
+		return "", fmt.Errorf("%s is not part of a module", dir)
+	}
+
+	f, err := os.Open(filepath.Join(root, GoModFile))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(l, goModuleDirective) {
+			continue
+		}
+		mp := strings.TrimSpace(strings.TrimPrefix(l, goModuleDirective))
+		if i := strings.Index(mp, "//"); i >= 0 {
+			mp = strings.TrimSpace(mp[:i])
+		}
+		mp = strings.Trim(mp, "\"`")
+		if mp != "" {
+			return mp, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("No module directive in %s", filepath.Join(root, GoModFile))
+}
+
+// ImportPath resolves the import path of a package directory relative to projectRoot (e.g., "/index/upsidedown").
+// Module projects are resolved against their module path, GOPATH projects against the src folder.
+func ImportPath(projectRoot, pkg string) (string, error) {
+	dir, err := filepath.Abs(filepath.Join(projectRoot, pkg))
+	if err != nil {
+		return "", err
+	}
+
+	if root, ok := ModuleRoot(dir); ok {
+		mp, err := ModulePath(root)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(mp, filepath.ToSlash(rel)), nil
+	}
+
+	pathArr := strings.Split(dir, string(filepath.Separator))
+	for i, el := range pathArr {
+		if el == srcFolder {
+			return path.Join(pathArr[i+1:]...), nil
+		}
+	}
+	return "", fmt.Errorf("%s is neither part of a module nor of a GOPATH", dir)
+}
+
+// IsVendored reports whether the module with root folder root is vendored (vendor/modules.txt exists).
+func IsVendored(root string) bool {
+	_, err := os.Stat(filepath.Join(root, goModVendorFile))
+	return err == nil
+}
+
+// ProjectEnv returns the environment to execute go commands for a project in.
+// Module projects are executed in module mode, which never updates go.mod (-mod=readonly, the default) unless
+// configured otherwise in GOFLAGS; vendored modules use their vendor folder (-mod=vendor).
+// All other projects are executed in GOPATH mode with the GOPATH derived from the project path.
+func ProjectEnv(goRoot, projectRoot string) []string {
+	root, ok := ModuleRoot(projectRoot)
+	if !ok {
+		env := Env(goRoot, GoPath(projectRoot))
+		return SetEnv(env, go111ModuleVar, "off")
+	}
+
+	env := Env(goRoot, "")
+	env = SetEnv(env, go111ModuleVar, "on")
+	if !IsVendored(root) {
+		return env
+	}
+	return SetEnv(env, goFlagsVar, withModFlag(LookupEnv(env, goFlagsVar), goModVendor))
+}
+
+func withModFlag(goFlags, mod string) string {
+	flags := strings.Fields(goFlags)
+	ret := make([]string, 0, len(flags)+1)
+	for _, f := range flags {
+		if strings.HasPrefix(f, goFlagsMod) {
+			continue
+		}
+		ret = append(ret, f)
+	}
+	ret = append(ret, goFlagsMod+mod)
+	return strings.Join(ret, " ")
+}
+
+// LookupEnv returns the value of variable key of env, empty if not set.
+func LookupEnv(env []string, key string) string {
+	prefix := key + "="
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return e[len(prefix):]
+		}
+	}
+	return ""
+}
+
+// SetEnv sets the variable key of env to value.
+func SetEnv(env []string, key, value string) []string {
+	decl := fmt.Sprintf("%s=%s", key, value)
+	prefix := key + "="
+	for i, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			env[i] = decl
+			return env
+		}
+	}
+	return append(env, decl)
+}
@@ -0,0 +1,123 @@
+package executil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for p, src := range files {
+		p = filepath.Join(dir, p)
+		err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		if err != nil {
+			t.Fatalf("Could not create folder: %v", err)
+		}
+		err = ioutil.WriteFile(p, []byte(src), os.ModePerm)
+		if err != nil {
+			t.Fatalf("Could not write %s: %v", p, err)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "executil_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"mod/go.mod":                       "// comment\nmodule \"example.com/mod\" // path\n",
+		"mod/pkg/a.go":                     "package pkg\n",
+		"vendored/go.mod":                  "module example.com/vendored\n",
+		"vendored/vendor/modules.txt":      "",
+		"gopath/src/example.com/gp/a.go":   "package gp\n",
+		"gopath/src/example.com/gp/b/b.go": "package b\n",
+	})
+	mod := filepath.Join(dir, "mod")
+	vendored := filepath.Join(dir, "vendored")
+	gp := filepath.Join(dir, "gopath", "src", "example.com", "gp")
+
+	if root, ok := ModuleRoot(filepath.Join(mod, "pkg")); !ok || root != mod {
+		t.Errorf("Expected module root %s, was '%s' (%t)", mod, root, ok)
+	}
+	if !IsModuleRoot(mod) || IsModuleRoot(filepath.Join(mod, "pkg")) {
+		t.Errorf("Unexpected module root")
+	}
+	if IsModule(gp) {
+		t.Errorf("Expected %s not to be part of a module", gp)
+	}
+
+	if mp, err := ModulePath(filepath.Join(mod, "pkg")); err != nil || mp != "example.com/mod" {
+		t.Errorf("Expected module path example.com/mod, was '%s' (%v)", mp, err)
+	}
+	if _, err := ModulePath(gp); err == nil {
+		t.Errorf("Expected error for module path of GOPATH project")
+	}
+
+	tests := []struct {
+		root, pkg, exp string
+	}{
+		{mod, "", "example.com/mod"},
+		{mod, "/pkg", "example.com/mod/pkg"},
+		{gp, "/b", "example.com/gp/b"},
+	}
+	for _, test := range tests {
+		if ip, err := ImportPath(test.root, test.pkg); err != nil || ip != test.exp {
+			t.Errorf("Expected import path %s, was '%s' (%v)", test.exp, ip, err)
+		}
+	}
+
+	if IsVendored(mod) || !IsVendored(vendored) {
+		t.Errorf("Unexpected vendoring")
+	}
+}
+
+func TestProjectEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "executil_test")
+	if err != nil {
+		t.Fatalf("Could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"mod/go.mod":                     "module example.com/mod\n",
+		"vendored/go.mod":                "module example.com/vendored\n",
+		"vendored/vendor/modules.txt":    "",
+		"gopath/src/example.com/gp/a.go": "package gp\n",
+	})
+
+	defer os.Setenv(goFlagsVar, os.Getenv(goFlagsVar))
+	os.Setenv(goFlagsVar, "-mod=mod -v")
+
+	env := ProjectEnv("", filepath.Join(dir, "mod"))
+	if v := LookupEnv(env, go111ModuleVar); v != "on" {
+		t.Errorf("Expected module mode, was '%s'", v)
+	}
+	if v := LookupEnv(env, goFlagsVar); v != "-mod=mod -v" {
+		t.Errorf("Expected configured go flags, was '%s'", v)
+	}
+
+	env = ProjectEnv("", filepath.Join(dir, "vendored"))
+	if v := LookupEnv(env, goFlagsVar); v != "-v -mod=vendor" {
+		t.Errorf("Expected vendor mode, was '%s'", v)
+	}
+
+	env = ProjectEnv("", filepath.Join(dir, "gopath", "src", "example.com", "gp"))
+	if v := LookupEnv(env, go111ModuleVar); v != "off" {
+		t.Errorf("Expected GOPATH mode, was '%s'", v)
+	}
+	if v := LookupEnv(env, goPathVariable); v != filepath.Join(dir, "gopath") {
+		t.Errorf("Expected GOPATH %s, was '%s'", filepath.Join(dir, "gopath"), v)
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	env := SetEnv([]string{"A=1", "AB=2"}, "A", "3")
+	env = SetEnv(env, "C", "4")
+	if v := LookupEnv(env, "A"); v != "3" || len(env) != 3 || LookupEnv(env, "AB") != "2" || LookupEnv(env, "C") != "4" {
+		t.Errorf("Unexpected environment %v", env)
+	}
+}
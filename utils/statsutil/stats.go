@@ -0,0 +1,264 @@
+package statsutil
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	betaMaxIterations = 300
+	betaEpsilon       = 3e-14
+	betaFpMin         = 1e-300
+)
+
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// Variance returns the (unbiased) sample variance of xs.
+func Variance(xs []float64) float64 {
+	l := len(xs)
+	if l < 2 {
+		return math.NaN()
+	}
+	m := Mean(xs)
+	var sum float64
+	for _, x := range xs {
+		d := x - m
+		sum += d * d
+	}
+	return sum / float64(l-1)
+}
+
+func StdDev(xs []float64) float64 {
+	return math.Sqrt(Variance(xs))
+}
+
+func Median(xs []float64) float64 {
+	l := len(xs)
+	if l == 0 {
+		return math.NaN()
+	}
+	s := sorted(xs)
+	if l%2 == 1 {
+		return s[l/2]
+	}
+	return (s[l/2-1] + s[l/2]) / 2
+}
+
+func sorted(xs []float64) []float64 {
+	s := make([]float64, len(xs))
+	copy(s, xs)
+	sort.Float64s(s)
+	return s
+}
+
+// NormalCDF is the cumulative distribution function of the standard normal distribution.
+func NormalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// StudentTCDF is the cumulative distribution function of Student's t-distribution with df degrees of freedom.
+func StudentTCDF(t, df float64) float64 {
+	x := df / (df + t*t)
+	p := 0.5 * RegIncBeta(df/2, 0.5, x)
+	if t > 0 {
+		return 1 - p
+	}
+	return p
+}
+
+// RegIncBeta is the regularised incomplete beta function I_x(a, b).
+func RegIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// continued fraction converges rapidly for x < (a+1)/(a+b+2)
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF evaluates the continued fraction of the incomplete beta function (modified Lentz's method).
+func betaCF(a, b, x float64) float64 {
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < betaFpMin {
+		d = betaFpMin
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= betaMaxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < betaFpMin {
+			d = betaFpMin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < betaFpMin {
+			c = betaFpMin
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < betaFpMin {
+			d = betaFpMin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < betaFpMin {
+			c = betaFpMin
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < betaEpsilon {
+			break
+		}
+	}
+	return h
+}
+
+// MannWhitneyU performs a one-sided Mann-Whitney U test (normal approximation with tie and continuity correction)
+// with the alternative hypothesis that values of x are stochastically greater than values of y.
+// It returns the p-value.
+func MannWhitneyU(x, y []float64) float64 {
+	nx := len(x)
+	ny := len(y)
+	if nx == 0 || ny == 0 {
+		return 1
+	}
+
+	type obs struct {
+		v   float64
+		inX bool
+	}
+	all := make([]obs, 0, nx+ny)
+	for _, v := range x {
+		all = append(all, obs{v: v, inX: true})
+	}
+	for _, v := range y {
+		all = append(all, obs{v: v})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// assign mid-ranks to ties
+	n := len(all)
+	var rankSumX, tieCorr float64
+	for i := 0; i < n; {
+		j := i
+		for j < n && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].inX {
+				rankSumX += rank
+			}
+		}
+		t := float64(j - i)
+		tieCorr += t*t*t - t
+		i = j
+	}
+
+	fnx := float64(nx)
+	fny := float64(ny)
+	fn := float64(n)
+	u := rankSumX - fnx*(fnx+1)/2
+	mu := fnx * fny / 2
+	sigma := math.Sqrt(fnx * fny / 12 * ((fn + 1) - tieCorr/(fn*(fn-1))))
+	if sigma == 0 || math.IsNaN(sigma) {
+		return 1
+	}
+	z := (u - mu - 0.5) / sigma
+	return 1 - NormalCDF(z)
+}
+
+// WelchTTest performs a one-sided Welch's t-test with the alternative hypothesis that the mean of x is greater than the mean of y.
+// It returns the p-value.
+func WelchTTest(x, y []float64) float64 {
+	nx := float64(len(x))
+	ny := float64(len(y))
+	if nx < 2 || ny < 2 {
+		return 1
+	}
+	vx := Variance(x) / nx
+	vy := Variance(y) / ny
+	se := math.Sqrt(vx + vy)
+	if se == 0 {
+		if Mean(x) > Mean(y) {
+			return 0
+		}
+		return 1
+	}
+	t := (Mean(x) - Mean(y)) / se
+	df := (vx + vy) * (vx + vy) / (vx*vx/(nx-1) + vy*vy/(ny-1))
+	return 1 - StudentTCDF(t, df)
+}
+
+// BootstrapMeanCI returns the percentile bootstrap confidence interval of the mean of the measurements in groups (e.g., runs).
+// Every resample draws groups with replacement and then measurements within each drawn group with replacement (hierarchical bootstrap).
+func BootstrapMeanCI(groups [][]float64, confidence float64, resamples int, r *rand.Rand) (float64, float64) {
+	return BootstrapCI(groups, Mean, confidence, resamples, r)
+}
+
+// BootstrapCI is BootstrapMeanCI for an arbitrary statistic (e.g., Median).
+func BootstrapCI(groups [][]float64, statistic func([]float64) float64, confidence float64, resamples int, r *rand.Rand) (float64, float64) {
+	nonEmpty := make([][]float64, 0, len(groups))
+	n := 0
+	for _, g := range groups {
+		if len(g) > 0 {
+			nonEmpty = append(nonEmpty, g)
+			n += len(g)
+		}
+	}
+	if len(nonEmpty) == 0 || resamples < 1 {
+		return math.NaN(), math.NaN()
+	}
+
+	stats := make([]float64, resamples)
+	sample := make([]float64, 0, n)
+	for i := range stats {
+		sample = sample[:0]
+		for range nonEmpty {
+			g := nonEmpty[r.Intn(len(nonEmpty))]
+			for range g {
+				sample = append(sample, g[r.Intn(len(g))])
+			}
+		}
+		stats[i] = statistic(sample)
+	}
+	sort.Float64s(stats)
+
+	alpha := (1 - confidence) / 2
+	return quantile(stats, alpha), quantile(stats, 1-alpha)
+}
+
+// quantile returns the q-quantile of the sorted xs (linear interpolation)
+func quantile(xs []float64, q float64) float64 {
+	pos := q * float64(len(xs)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return xs[lo] + (xs[hi]-xs[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,65 @@
+package statsutil
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const epsilon = 1e-4
+
+func assertClose(name string, expected, was float64, t *testing.T) {
+	if math.Abs(expected-was) > epsilon {
+		t.Errorf("Unexpected %s: expected %f, was %f", name, expected, was)
+	}
+}
+
+func TestDescriptive(t *testing.T) {
+	xs := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	assertClose("mean", 5, Mean(xs), t)
+	assertClose("median", 4.5, Median(xs), t)
+	assertClose("variance", 32.0/7, Variance(xs), t)
+}
+
+func TestStudentTCDF(t *testing.T) {
+	assertClose("cdf(0, 5)", 0.5, StudentTCDF(0, 5), t)
+	assertClose("cdf(2, 10)", 0.963306, StudentTCDF(2, 10), t)
+	assertClose("cdf(-2, 10)", 0.036694, StudentTCDF(-2, 10), t)
+}
+
+func TestWelchTTest(t *testing.T) {
+	x := []float64{20.1, 19.8, 20.5, 21.0, 20.2}
+	y := []float64{10.2, 9.9, 10.4, 10.0, 10.1}
+	if p := WelchTTest(x, y); p >= 0.001 {
+		t.Errorf("Expected significant difference, p was %f", p)
+	}
+	if p := WelchTTest(y, x); p <= 0.999 {
+		t.Errorf("Expected no significant difference, p was %f", p)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	x := []float64{7, 8, 9, 10, 11}
+	y := []float64{1, 2, 3, 4, 5}
+	// U = 25, mu = 12.5, sigma = sqrt(25*11/12)
+	expected := 1 - NormalCDF((25-12.5-0.5)/math.Sqrt(25.0*11/12))
+	assertClose("p", expected, MannWhitneyU(x, y), t)
+	if p := MannWhitneyU(y, x); p < 0.5 {
+		t.Errorf("Expected no significant difference, p was %f", p)
+	}
+}
+
+func TestBootstrapMeanCI(t *testing.T) {
+	groups := [][]float64{{9, 10, 11}, {10, 10, 10}, {9.5, 10.5, 10}}
+	lo, hi := BootstrapMeanCI(groups, 0.95, 1000, rand.New(rand.NewSource(1)))
+	if !(lo < 10 && 10 < hi) {
+		t.Errorf("Expected confidence interval to contain the mean 10, was [%f, %f]", lo, hi)
+	}
+	if hi-lo > 2 {
+		t.Errorf("Unexpectedly wide confidence interval [%f, %f]", lo, hi)
+	}
+
+	lo, hi = BootstrapMeanCI([][]float64{{5, 5}}, 0.95, 100, rand.New(rand.NewSource(1)))
+	assertClose("constant lower bound", 5, lo, t)
+	assertClose("constant upper bound", 5, hi, t)
+}